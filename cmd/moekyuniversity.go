@@ -1,35 +1,302 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"moekyuniversity/internal/cards"
 )
 
 var (
-	cardsFile = flag.String("cards-file", "data/cards.json", "Cards file")
-	dataDir   = flag.String("data-dir", "data", "Data directory")
-	backupDir = flag.String("backup-dir", "data/backup", "Backup directory")
-	staticDir = flag.String("static-dir", "static", "Static directory")
+	configFile        = flag.String("config", "", "JSON config file. Flags and MOEKYU_ environment variables override its settings.")
+	cardsFile         = flag.String("cards-file", "data/cards.json", "Cards file")
+	dataDir           = flag.String("data-dir", "data", "Data directory")
+	backupDir         = flag.String("backup-dir", "data/backup", "Backup directory")
+	staticDir         = flag.String("static-dir", "static", "Static directory, used with -dev")
+	dev               = flag.Bool("dev", false, "Read templates and static files from -static-dir on every request, instead of the copies built into the binary")
+	dictionaryFile    = flag.String("dictionary-file", "", "JMdict file (default JMdict_e in the data directory)")
+	kanjiFrequencyDir = flag.String("kanji-frequency-dir", "", "Kanji frequency lists directory (default kanji_frequencies in the data directory)")
+
+	scheduler                  = flag.String("scheduler", "doubling", "SRS scheduler (doubling, fsrs)")
+	srsInitialLearningInterval = flag.Int("srs-initial-learning-interval", cards.DefaultSrsSettings.InitialLearningInterval, "Hours until a card that has just been learned or forgotten is reviewed again")
+	srsBurnInterval            = flag.Int("srs-burn-interval", cards.DefaultSrsSettings.BurnInterval, "Interval in hours at which a card is burned")
+	srsLeechThreshold          = flag.Int("srs-leech-threshold", cards.DefaultSrsSettings.LeechThreshold, "Times in a row a learned card can be forgotten before it is a leech")
+	srsLeechAction             = flag.String("srs-leech-action", string(cards.DefaultSrsSettings.LeechAction), "What to do with leeches: tag, suspend, or relearn")
+	srsNewCardsPerDay          = flag.Int("srs-new-cards-per-day", 0, "Most new cards that can be started each day (0 for no limit)")
+	srsReviewsPerDay           = flag.Int("srs-reviews-per-day", 0, "Most reviews of learned cards each day (0 for no limit)")
+	srsFuzz                    = flag.Bool("srs-fuzz", true, "Move learned reviews a little earlier or later, to the least busy time, so reviews don't bunch up")
+	dayStart                   = flag.String("day-start", "00:00", "Time each study day starts, for the daily limits and historical data")
+	timezone                   = flag.String("timezone", "", "Timezone of -day-start, e.g. Europe/London (default local time)")
+
+	backupKeepLast    = flag.Int("backup-keep-last", cards.DefaultRetentionPolicy.KeepLast, "Number of most recent backups to keep")
+	backupKeepDaily   = flag.Int("backup-keep-daily", cards.DefaultRetentionPolicy.Daily, "Number of days to keep a daily backup for")
+	backupKeepWeekly  = flag.Int("backup-keep-weekly", cards.DefaultRetentionPolicy.Weekly, "Number of weeks to keep a weekly backup for")
+	backupKeepMonthly = flag.Int("backup-keep-monthly", cards.DefaultRetentionPolicy.Monthly, "Number of months to keep a monthly backup for")
+	listBackups       = flag.Bool("list-backups", false, "List backups and exit")
+	restoreBackup     = flag.String("restore-backup", "", "Show what restoring the named backup would change, restore it if confirmed, and exit")
+	importContent     = flag.String("import-content", "", "Update card content from the given cards file, keeping progress, and exit")
+	addUser           = flag.String("add-user", "", "Add a user, prompting for their password, and exit")
+	user              = flag.String("user", "", "User whose profile -list-backups, -restore-backup and -import-content work on")
+
+	listen          = flag.String("listen", ":8080", "Address to listen on")
+	tlsCert         = flag.String("tls-cert", "", "TLS certificate file. Serves HTTPS when given with -tls-key.")
+	tlsKey          = flag.String("tls-key", "", "TLS private key file")
+	readTimeout     = flag.Duration("read-timeout", 30*time.Second, "Longest time to read a request")
+	writeTimeout    = flag.Duration("write-timeout", 60*time.Second, "Longest time to write a response")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "Longest time to wait for requests to finish when shutting down")
 )
 
 func main() {
-	// Read flags and log
+	// Read flags, the environment and the config file, and log
 	flag.Parse()
+	err := cards.LoadConfig(flag.CommandLine, "config", os.Environ())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *configFile != "" {
+		log.Printf("Config file: %s", *configFile)
+	}
 	log.Printf("Cards file: %s", *cardsFile)
 	log.Printf("Data directory: %s", *dataDir)
 	log.Printf("Backup directory: %s", *backupDir)
-	log.Printf("Static directory: %s", *staticDir)
+	if *dev {
+		log.Printf("Dev mode, serving static files from %s", *staticDir)
+	}
+	log.Printf("Scheduler: %s", *scheduler)
+
+	leechAction, err := cards.ParseLeechAction(*srsLeechAction)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := cards.NewScheduler(*scheduler, cards.SrsSettings{
+		InitialLearningInterval: *srsInitialLearningInterval,
+		BurnInterval:            *srsBurnInterval,
+		LeechThreshold:          *srsLeechThreshold,
+		LeechAction:             leechAction,
+		NewCardsPerDay:          *srsNewCardsPerDay,
+		ReviewsPerDay:           *srsReviewsPerDay,
+		NoFuzz:                  !*srsFuzz,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	dayBoundary, err := cards.ParseDayBoundary(*dayStart, *timezone)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Loading the cards backs them up and prunes old backups. Restoring and importing keep every backup,
+	// so the backup being restored can't be pruned before it is read.
+	retention := cards.RetentionPolicy{
+		KeepLast: *backupKeepLast,
+		Daily:    *backupKeepDaily,
+		Weekly:   *backupKeepWeekly,
+		Monthly:  *backupKeepMonthly,
+	}
+	if *restoreBackup != "" || *importContent != "" {
+		retention = cards.RetentionPolicy{}
+	}
 
 	cardData := cards.CardData{
-		CardsFile: *cardsFile,
-		DataDir:   *dataDir,
-		BackupDir: *backupDir,
-		StaticDir: *staticDir,
-	}
-	cardData.LoadCardJson()
-	cardData.LoadDictionary()
-	go cards.DoHistoricalData(&cardData)
-	cards.SetupRoutes(&cardData)
+		CardsFile:         *cardsFile,
+		DataDir:           *dataDir,
+		BackupDir:         *backupDir,
+		StaticDir:         *staticDir,
+		Dev:               *dev,
+		DictionaryFile:    *dictionaryFile,
+		KanjiFrequencyDir: *kanjiFrequencyDir,
+		Scheduler:         s,
+		BackupRetention:   retention,
+		DayBoundary:       dayBoundary,
+	}
+
+	users, err := cards.LoadUsers(cards.UsersFile(*dataDir))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *addUser != "" {
+		doAddUser(users, *addUser)
+		return
+	}
+
+	if *listBackups {
+		doListBackups(selectProfile(&cardData, users))
+		return
+	}
+
+	server, err := cards.NewServer(&cardData, users)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *restoreBackup != "" || *importContent != "" {
+		cd, err := server.Profile(*user)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *restoreBackup != "" {
+			doRestoreBackup(cd, *restoreBackup)
+			return
+		}
+
+		added, updated, err := cd.ImportContent(*importContent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Imported %s: %d cards added, %d cards updated\n", *importContent, added, updated)
+		return
+	}
+
+	server.LoadDictionary()
+
+	// Shut down cleanly on Ctrl-C or SIGTERM, e.g. from docker stop
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s", sig)
+		cancel()
+	}()
+
+	err = server.Run(ctx, cards.ListenConfig{
+		Addr:            *listen,
+		TLSCertFile:     *tlsCert,
+		TLSKeyFile:      *tlsKey,
+		ReadTimeout:     *readTimeout,
+		WriteTimeout:    *writeTimeout,
+		IdleTimeout:     2 * time.Minute,
+		ShutdownTimeout: *shutdownTimeout,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Stopped")
+}
+
+// The profile for -user, without loading its cards
+func selectProfile(cd *cards.CardData, users *cards.UserStore) *cards.CardData {
+	if len(users.Names()) == 0 {
+		if *user != "" {
+			log.Fatal("There are no users")
+		}
+		return cd
+	}
+	for _, name := range users.Names() {
+		if name == *user {
+			return cd.ForProfile(name)
+		}
+	}
+	if *user == "" {
+		log.Fatal("-user is required when there are users")
+	}
+	log.Fatalf("Unknown user %s", *user)
+	return nil
+}
+
+// The first user takes over the progress made before there were users
+func doAddUser(users *cards.UserStore, name string) {
+	fmt.Printf("Password for %s: ", name)
+	password, err := readPassword()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	first := len(users.Names()) == 0
+	err = users.Add(name, password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if first {
+		err = cards.SeedProfile(*dataDir, *cardsFile, cards.ProfileDirFor(*dataDir, name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Copied the existing progress to %s\n", name)
+	}
+
+	err = users.Save()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Added", name)
+}
+
+// Read a line from stdin, without echoing it if stdin is a terminal.
+// Echo is turned off with stty, and turned back on even if interrupted.
+func readPassword() (string, error) {
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if stty("-echo") == nil {
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-interrupted:
+				stty("echo")
+				fmt.Println()
+				os.Exit(1)
+			case <-done:
+			}
+		}()
+		defer func() {
+			close(done)
+			signal.Stop(interrupted)
+			stty("echo")
+			fmt.Println()
+		}()
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+func doListBackups(cd *cards.CardData) {
+	backups, err := cd.ListBackups()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, b := range backups {
+		fmt.Printf("%s\t%s\t%d KiB\n", b.Name, b.Time.Local().Format("2006-01-02 15:04:05"), b.SizeKiB())
+	}
+}
+
+func doRestoreBackup(cd *cards.CardData, name string) {
+	diff, err := cd.DiffBackup(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(diff)
+
+	fmt.Print("Restore this backup? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+		fmt.Println("Not restored")
+		return
+	}
+
+	err = cd.RestoreBackup(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Restored", name)
 }
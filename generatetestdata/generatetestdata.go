@@ -2,12 +2,14 @@ package main
 
 import (
 	"flag"
+	"log"
 	"time"
 	"moekyuniversity/internal/cards"
 )
 
 var (
 	cardsFile = flag.String("cards-file", "data/cards.json", "Cards file")
+	dataDir = flag.String("data-dir", "data", "Data directory")
 	backupDir = flag.String("backup-dir", "data/backup", "Backup directory")
 )
 
@@ -41,7 +43,7 @@ func setUpNext(c *cards.Card) {
 
 func main() {
 	flag.Parse()
-	cardData := cards.CardData{CardsFile: *cardsFile, BackupDir: *backupDir}
+	cardData := cards.CardData{CardsFile: *cardsFile, DataDir: *dataDir, BackupDir: *backupDir}
 
 	cs := make(map[int]*cards.Card)
 	var i int
@@ -152,5 +154,8 @@ func main() {
 	}
 
 	cardData.Cards = cs
-	cardData.SaveCardMap()
+	err := cardData.SaveCardMap()
+	if err != nil {
+		log.Fatal(err)
+	}
 }
\ No newline at end of file
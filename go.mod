@@ -1,6 +1,6 @@
 module moekyuniversity
 
-go 1.13
+go 1.16
 
 require (
 	foosoft.net/projects/jmdict v0.0.0-20220714211640-cc9bc30b68a3
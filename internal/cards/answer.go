@@ -0,0 +1,232 @@
+package cards
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mochi-co/kana-tools"
+)
+
+// Typed answers are checked against the card's accepted meanings and readings.
+// Meanings are forgiving of typos. Your synonyms for a card are accepted too, and your blocked answers never are. Readings must be exact, but can be typed in romaji or katakana.
+// Checking doesn't grade the card: the answer is still graded with AnswerCard.
+
+// How a typed answer compares to the card's answers
+type AnswerVerdict string
+
+const (
+	AnswerCorrect      AnswerVerdict = "correct"
+	AnswerClose        AnswerVerdict = "close"         // A meaning with a typo, which counts as correct
+	AnswerOtherReading AnswerVerdict = "other_reading" // A reading of the card, but not the one asked for. Try again.
+	AnswerIncorrect    AnswerVerdict = "incorrect"
+)
+
+type AnswerCheck struct {
+	Verdict  AnswerVerdict
+	Answer   string // The answer as it was compared: readings are converted to hiragana
+	Expected string // The answer it matched or was closest to, or the primary answer if it was incorrect
+	Message  string // Shown to the user, e.g. "Did you mean ...?"
+}
+
+// Check a typed answer to a facet of the card
+func (c *Card) CheckAnswer(f Facet, answer string) AnswerCheck {
+	if f == ReadingFacet {
+		return c.checkReading(answer)
+	}
+	return c.checkMeaning(answer)
+}
+
+func (c *Card) checkMeaning(answer string) AnswerCheck {
+	a := normaliseMeaning(answer)
+	check := AnswerCheck{Verdict: AnswerIncorrect, Answer: a, Expected: c.primaryMeaning()}
+
+	// Blocked answers are often one typo away from a meaning, but mean something else
+	for _, b := range c.BlockedAnswers {
+		if a == normaliseMeaning(b) {
+			check.Message = fmt.Sprintf("%q is blocked for this card", b)
+			return check
+		}
+	}
+
+	best := -1
+	var closest string
+	for _, m := range c.acceptedMeanings() {
+		d := levenshtein(a, normaliseMeaning(m))
+		if best == -1 || d < best {
+			best = d
+			closest = m
+		}
+	}
+
+	switch {
+	case best == 0:
+		check.Verdict = AnswerCorrect
+		check.Expected = closest
+	case best > 0 && best <= typoTolerance(len([]rune(normaliseMeaning(closest)))):
+		check.Verdict = AnswerClose
+		check.Expected = closest
+		check.Message = fmt.Sprintf("Did you mean %q?", closest)
+	}
+	return check
+}
+
+// The card's accepted meanings, followed by your synonyms
+func (c *Card) acceptedMeanings() []string {
+	var meanings []string
+	for _, m := range c.Meanings {
+		if m.AcceptedAnswer {
+			meanings = append(meanings, m.Meaning)
+		}
+	}
+	return append(meanings, c.UserSynonyms...)
+}
+
+func (c *Card) checkReading(answer string) AnswerCheck {
+	a := normaliseReading(answer)
+	check := AnswerCheck{Verdict: AnswerIncorrect, Answer: a, Expected: c.primaryReading().Reading}
+
+	for _, r := range c.Readings {
+		if r.AcceptedAnswer && a == normaliseReading(r.Reading) {
+			check.Verdict = AnswerCorrect
+			check.Expected = r.Reading
+			return check
+		}
+	}
+
+	// Knowing another reading isn't wrong, but isn't the answer either
+	for _, r := range c.Readings {
+		if !r.AcceptedAnswer && a == normaliseReading(r.Reading) {
+			check.Verdict = AnswerOtherReading
+			want := c.primaryReading().Type
+			if r.Type != "" && want != "" && r.Type != want {
+				check.Message = fmt.Sprintf("That's the %s, we want the %s", readingTypeName(r.Type), readingTypeName(want))
+			} else {
+				check.Message = "That's a reading of this card, but not the one we want"
+			}
+			return check
+		}
+	}
+	return check
+}
+
+// Trimmed, without blanks or answers that are the same once normalised
+func cleanAnswers(answers []string) []string {
+	var cleaned []string
+	seen := map[string]bool{}
+	for _, a := range answers {
+		a = strings.TrimSpace(a)
+		n := normaliseMeaning(a)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cleaned = append(cleaned, a)
+	}
+	return cleaned
+}
+
+// The primary meaning, or the first accepted one if none is marked primary
+func (c *Card) primaryMeaning() string {
+	var first string
+	for _, m := range c.Meanings {
+		if m.Primary && m.AcceptedAnswer {
+			return m.Meaning
+		}
+		if first == "" && m.AcceptedAnswer {
+			first = m.Meaning
+		}
+	}
+	return first
+}
+
+// The primary reading, or the first accepted one if none is marked primary
+func (c *Card) primaryReading() Reading {
+	var first Reading
+	for _, r := range c.Readings {
+		if r.Primary && r.AcceptedAnswer {
+			return r
+		}
+		if first.Reading == "" && r.AcceptedAnswer {
+			first = r
+		}
+	}
+	return first
+}
+
+// Lower case, with punctuation removed and spaces collapsed, so "Ice-cream!" matches "ice cream"
+func normaliseMeaning(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		case r == '\'':
+			return -1
+		}
+		return ' '
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// A double n before a consonant or at the end is ん, as typed with an IME, and not ん followed by a stray n
+var doubleN = regexp.MustCompile(`nn([^aiueoy]|$)`)
+
+// Hiragana, whether typed in romaji, katakana or hiragana
+func normaliseReading(s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	s = doubleN.ReplaceAllString(s, "n'$1")
+	return kana.ToHiragana(s)
+}
+
+func readingTypeName(t string) string {
+	switch t {
+	case "onyomi":
+		return "on'yomi"
+	case "kunyomi":
+		return "kun'yomi"
+	}
+	return t
+}
+
+// The number of typos allowed in a meaning of n characters. Short words must be exact,
+// as a single typo is often another word.
+func typoTolerance(n int) int {
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 5:
+		return 1
+	case n <= 7:
+		return 2
+	}
+	return 2 + n/7
+}
+
+// The number of single character insertions, deletions and substitutions to turn a into b
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
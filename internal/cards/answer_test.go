@@ -0,0 +1,171 @@
+package cards
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckMeaning(t *testing.T) {
+	c := createKanjiCard(1)
+	c.Meanings = []Meaning{
+		{Meaning: "Ice Cream", Primary: true, AcceptedAnswer: true},
+		{Meaning: "Sorbet", AcceptedAnswer: true},
+		{Meaning: "Dessert", AcceptedAnswer: false},
+	}
+
+	tests := []struct {
+		answer   string
+		verdict  AnswerVerdict
+		expected string
+	}{
+		{"ice cream", AnswerCorrect, "Ice Cream"},
+		{" ice-cream! ", AnswerCorrect, "Ice Cream"},
+		{"ice craem", AnswerClose, "Ice Cream"},
+		{"sorbert", AnswerClose, "Sorbet"},
+		{"dessert", AnswerIncorrect, "Ice Cream"},
+		{"cake", AnswerIncorrect, "Ice Cream"},
+	}
+	for _, test := range tests {
+		check := c.CheckAnswer(MeaningFacet, test.answer)
+		if check.Verdict != test.verdict || check.Expected != test.expected {
+			t.Errorf("Expected %q to be %s for %q, got %s for %q", test.answer, test.verdict, test.expected, check.Verdict, check.Expected)
+		}
+	}
+
+	// A single typo in a short word is often another word
+	c.Meanings = []Meaning{{Meaning: "cat", Primary: true, AcceptedAnswer: true}}
+	if check := c.CheckAnswer(MeaningFacet, "car"); check.Verdict != AnswerIncorrect {
+		t.Errorf("Expected car to be incorrect for cat, got %s", check.Verdict)
+	}
+}
+
+func TestCheckUserAnswers(t *testing.T) {
+	c := createKanjiCard(1)
+	c.Meanings = []Meaning{{Meaning: "Lager", Primary: true, AcceptedAnswer: true}}
+	c.UserSynonyms = []string{"Pale Beer"}
+	c.BlockedAnswers = []string{"Large"}
+
+	if check := c.CheckAnswer(MeaningFacet, "pale beer"); check.Verdict != AnswerCorrect || check.Expected != "Pale Beer" {
+		t.Errorf("Expected a synonym to be correct, got %s for %q", check.Verdict, check.Expected)
+	}
+	if check := c.CheckAnswer(MeaningFacet, "pale bear"); check.Verdict != AnswerClose {
+		t.Errorf("Expected a synonym with a typo to be close, got %s", check.Verdict)
+	}
+	if check := c.CheckAnswer(MeaningFacet, "larger"); check.Verdict != AnswerClose {
+		t.Errorf("Expected larger to be close, got %s", check.Verdict)
+	}
+	if check := c.CheckAnswer(MeaningFacet, "large"); check.Verdict != AnswerIncorrect || check.Expected != "Lager" {
+		t.Errorf("Expected a blocked answer to be incorrect, got %s for %q", check.Verdict, check.Expected)
+	}
+}
+
+func TestCheckReading(t *testing.T) {
+	c := createKanjiCard(1)
+	c.Readings = []Reading{
+		{Reading: "にん", Type: "onyomi", AcceptedAnswer: false},
+		{Reading: "ひと", Type: "kunyomi", Primary: true, AcceptedAnswer: true},
+		{Reading: "じん", Type: "onyomi", AcceptedAnswer: false},
+	}
+
+	for _, answer := range []string{"ひと", "hito", "HITO", "ヒト"} {
+		check := c.CheckAnswer(ReadingFacet, answer)
+		if check.Verdict != AnswerCorrect || check.Answer != "ひと" {
+			t.Errorf("Expected %q to be correct, got %s for %q", answer, check.Verdict, check.Answer)
+		}
+	}
+
+	check := c.CheckAnswer(ReadingFacet, "jin")
+	if check.Verdict != AnswerOtherReading || check.Message != "That's the on'yomi, we want the kun'yomi" {
+		t.Errorf("Expected to be told that's the on'yomi, got %s %q", check.Verdict, check.Message)
+	}
+
+	// Readings have no typo tolerance
+	if check = c.CheckAnswer(ReadingFacet, "hiro"); check.Verdict != AnswerIncorrect || check.Expected != "ひと" {
+		t.Errorf("Expected hiro to be incorrect, got %s for %q", check.Verdict, check.Expected)
+	}
+
+	// ん can be typed as nn, as with an IME
+	c.Readings = []Reading{{Reading: "しんぶん", Primary: true, AcceptedAnswer: true}}
+	for _, answer := range []string{"shinbun", "shinnbunn"} {
+		if check = c.CheckAnswer(ReadingFacet, answer); check.Verdict != AnswerCorrect {
+			t.Errorf("Expected %q to be correct, got %s for %q", answer, check.Verdict, check.Answer)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"cat", "", 3},
+		{"kitten", "sitting", 3},
+		{"ねこ", "ねご", 1},
+	}
+	for _, test := range tests {
+		if d := levenshtein(test.a, test.b); d != test.d {
+			t.Errorf("Expected distance %d between %q and %q, got %d", test.d, test.a, test.b, d)
+		}
+	}
+}
+
+func TestApiCheck(t *testing.T) {
+	cd, h := createTestRouter(t, 1)
+	cd.Cards[2] = createKanjiCard(2)
+
+	w := apiRequest(h, "POST", "/api/v1/srs/checks", `{"card_id": 2, "facet": "reading", "answer": "neko"}`)
+	var result ApiCheckResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || result.Verdict != AnswerCorrect || result.Answer != "ねこ" {
+		t.Errorf("Expected neko to be correct, got %d %s", w.Code, w.Body.String())
+	}
+	if cd.Cards[2].ReadingReview != nil || cd.Cards[2].TotalTimesReviewed != 0 {
+		t.Errorf("Expected checking not to answer the card")
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/srs/checks", `{"card_id": 2, "answer": " "}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty answer, got %d", w.Code)
+	}
+	w = apiRequest(h, "POST", "/api/v1/srs/checks", `{"card_id": 1, "facet": "reading", "answer": "neko"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a card without a reading, got %d", w.Code)
+	}
+	w = apiRequest(h, "POST", "/api/v1/srs/checks", `{"card_id": 99, "answer": "cat"}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown card, got %d", w.Code)
+	}
+}
+
+func TestApiCardSynonyms(t *testing.T) {
+	cd, h := createTestRouter(t, 1)
+	cd.Cards[2] = createKanjiCard(2)
+
+	w := apiRequest(h, "PUT", "/api/v1/cards/2/synonyms", `{"user_synonyms": ["kitty", " Kitty ", ""], "blocked_answers": ["car"]}`)
+	var c Card
+	json.Unmarshal(w.Body.Bytes(), &c)
+	if w.Code != http.StatusOK || len(c.UserSynonyms) != 1 || c.UserSynonyms[0] != "kitty" || len(c.BlockedAnswers) != 1 {
+		t.Errorf("Expected one synonym and one blocked answer, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Synonyms are saved with the progress, not the content
+	if cards := loadSavedCards(t, cd); len(cards[2].UserSynonyms) != 1 {
+		t.Errorf("Expected the synonym to be saved, got %v", cards[2].UserSynonyms)
+	}
+	content, err := ioutil.ReadFile(cd.CardsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "kitty") {
+		t.Errorf("Expected the cards file not to hold the synonyms")
+	}
+
+	w = apiRequest(h, "PUT", "/api/v1/cards/99/synonyms", `{"user_synonyms": ["kitty"]}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown card, got %d", w.Code)
+	}
+}
@@ -0,0 +1,679 @@
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The JSON API under /api/v1, for scripts and other clients.
+// Responses are JSON. Errors are {"error": "..."} with a matching status code.
+// The API version only changes if existing responses change incompatibly.
+
+const apiPrefix = "/api/v1"
+
+// Largest request body accepted, to stop a client filling memory
+const apiMaxBody = 10 << 20
+
+func apiRoutes(r *mux.Router, handle func(cardDataHandler) http.HandlerFunc) {
+	api := r.PathPrefix(apiPrefix).Subrouter()
+
+	api.HandleFunc("/cards", handle((*CardData).ApiCardsHandler)).Methods("GET")
+	api.HandleFunc("/cards", handle((*CardData).ApiCardCreateHandler)).Methods("POST")
+	api.HandleFunc("/cards/{id:[0-9]+}", handle((*CardData).ApiCardHandler)).Methods("GET")
+	api.HandleFunc("/cards/{id:[0-9]+}", handle((*CardData).ApiCardUpdateHandler)).Methods("PUT")
+	api.HandleFunc("/cards/{id:[0-9]+}", handle((*CardData).ApiCardDeleteHandler)).Methods("DELETE")
+	api.HandleFunc("/cards/{id:[0-9]+}/revlog", handle((*CardData).ApiCardReviewLogHandler)).Methods("GET")
+	api.HandleFunc("/cards/{id:[0-9]+}/suspend", handle((*CardData).ApiCardSuspendHandler)).Methods("POST")
+	api.HandleFunc("/cards/{id:[0-9]+}/queue", handle((*CardData).ApiCardQueueHandler)).Methods("POST")
+	api.HandleFunc("/cards/{id:[0-9]+}/synonyms", handle((*CardData).ApiCardSynonymsHandler)).Methods("PUT")
+
+	api.HandleFunc("/srs/next", handle((*CardData).ApiSrsNextHandler)).Methods("GET")
+	api.HandleFunc("/srs/answers", handle((*CardData).ApiSrsAnswerHandler)).Methods("POST")
+	api.HandleFunc("/srs/checks", handle((*CardData).ApiSrsCheckHandler)).Methods("POST")
+	api.HandleFunc("/srs/undo", handle((*CardData).ApiSrsUndoHandler)).Methods("POST")
+
+	api.HandleFunc("/upnext", handle((*CardData).ApiUpNextHandler)).Methods("GET")
+	api.HandleFunc("/upnext", handle((*CardData).ApiUpNextAddHandler)).Methods("POST")
+
+	api.HandleFunc("/schedule", handle((*CardData).ApiScheduleHandler)).Methods("GET")
+	api.HandleFunc("/vacation", handle((*CardData).ApiVacationHandler)).Methods("GET")
+	api.HandleFunc("/vacation", handle((*CardData).ApiVacationStartHandler)).Methods("POST")
+	api.HandleFunc("/vacation", handle((*CardData).ApiVacationEndHandler)).Methods("DELETE")
+
+	api.HandleFunc("/dictionary", handle((*CardData).ApiDictionarySearchHandler)).Methods("GET")
+	api.HandleFunc("/dictionary/{id:[0-9]+}/card", handle((*CardData).ApiDictionaryAddCardHandler)).Methods("POST")
+
+	api.HandleFunc("/textanalyses", handle((*CardData).ApiTextAnalysesHandler)).Methods("GET")
+	api.HandleFunc("/textanalyses", handle((*CardData).ApiTextAnalysisCreateHandler)).Methods("POST")
+	api.HandleFunc("/textanalyses/{id}", handle((*CardData).ApiTextAnalysisHandler)).Methods("GET")
+	api.HandleFunc("/textanalyses/{id}", handle((*CardData).ApiTextAnalysisDeleteHandler)).Methods("DELETE")
+
+	api.HandleFunc("/stats/historical", handle((*CardData).ApiHistoricalStatsHandler)).Methods("GET")
+}
+
+type ApiError struct {
+	Error string `json:"error"`
+}
+
+// Encode the response before writing anything, so an encoding error can still be responded to
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+	return nil
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ApiError{Error: err.Error()})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBody)).Decode(v)
+	if err != nil {
+		return httpError(http.StatusBadRequest, fmt.Errorf("invalid JSON: %s", err))
+	}
+	return nil
+}
+
+func apiID(r *http.Request) int {
+	// The routes only match digits, so this can only fail on overflow, which no ID gets near
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return id
+}
+
+func validateCard(c *Card) error {
+	if c.NextReviewDate != "" {
+		_, err := time.Parse(time.RFC3339, c.NextReviewDate)
+		if err != nil {
+			return httpError(http.StatusBadRequest, fmt.Errorf("next_review_date: %s", err))
+		}
+	}
+	return nil
+}
+
+// GET /cards?q=&tag=
+// Every card, sorted by ID, or those matching the search and tag if given
+func (cd *CardData) ApiCardsHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	q := r.URL.Query()
+
+	var cs []*Card
+	if q.Get("q") != "" {
+		cs = s.Search(q.Get("q"))
+	} else {
+		cs = sortCardsById(s.ToList())
+	}
+	if q.Get("tag") != "" {
+		cs = filterCardsByTag(cs, q.Get("tag"))
+	}
+	if cs == nil {
+		cs = []*Card{}
+	}
+	return writeJSON(w, http.StatusOK, cs)
+}
+
+func (cd *CardData) ApiCardHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	c, err := s.getCardOrError(apiID(r))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, c)
+}
+
+// POST /cards
+// Add a card. Any ID in the body is ignored. Responds with the new card.
+func (cd *CardData) ApiCardCreateHandler(w http.ResponseWriter, r *http.Request) error {
+	var c Card
+	err := readJSON(w, r, &c)
+	if err == nil {
+		err = validateCard(&c)
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := cd.AddNewCard(&c)
+	if err != nil {
+		return err
+	}
+	return cd.writeCard(w, r, http.StatusCreated, id)
+}
+
+// PUT /cards/{id}
+// Replace a card
+func (cd *CardData) ApiCardUpdateHandler(w http.ResponseWriter, r *http.Request) error {
+	id := apiID(r)
+	if _, err := cd.Snapshot().getCardOrError(id); err != nil {
+		return err
+	}
+
+	var c Card
+	err := readJSON(w, r, &c)
+	if err == nil {
+		err = validateCard(&c)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.ID = id
+	err = cd.SaveCard(id, &c)
+	if err != nil {
+		return err
+	}
+	return cd.writeCard(w, r, http.StatusOK, id)
+}
+
+func (cd *CardData) ApiCardDeleteHandler(w http.ResponseWriter, r *http.Request) error {
+	err := cd.RemoveCard(apiID(r))
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (cd *CardData) ApiCardReviewLogHandler(w http.ResponseWriter, r *http.Request) error {
+	id := apiID(r)
+	if _, err := cd.Snapshot().getCardOrError(id); err != nil {
+		return err
+	}
+
+	entries, err := cd.GetCardReviewLog(id)
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []ReviewLogEntry{}
+	}
+	return writeJSON(w, http.StatusOK, entries)
+}
+
+func (cd *CardData) ApiCardSuspendHandler(w http.ResponseWriter, r *http.Request) error {
+	id := apiID(r)
+	err := cd.SuspendCard(id)
+	if err != nil {
+		return err
+	}
+	return cd.writeCard(w, r, http.StatusOK, id)
+}
+
+// POST /cards/{id}/queue
+// Queue an available card to be learned. Responds 409 if the card can't be queued.
+func (cd *CardData) ApiCardQueueHandler(w http.ResponseWriter, r *http.Request) error {
+	id := apiID(r)
+	err := cd.QueueCard(id)
+	if err != nil {
+		return err
+	}
+	return cd.writeCard(w, r, http.StatusOK, id)
+}
+
+type ApiUserAnswers struct {
+	UserSynonyms   []string `json:"user_synonyms"`   // Accepted as meanings
+	BlockedAnswers []string `json:"blocked_answers"` // Never accepted
+}
+
+// PUT /cards/{id}/synonyms
+// Replace your synonyms and blocked answers for the card. They are kept with your progress, not the content.
+func (cd *CardData) ApiCardSynonymsHandler(w http.ResponseWriter, r *http.Request) error {
+	var a ApiUserAnswers
+	err := readJSON(w, r, &a)
+	if err != nil {
+		return err
+	}
+
+	id := apiID(r)
+	err = cd.SetUserAnswers(id, a.UserSynonyms, a.BlockedAnswers)
+	if err != nil {
+		return err
+	}
+	return cd.writeCard(w, r, http.StatusOK, id)
+}
+
+// Respond with a card as it is now
+func (cd *CardData) writeCard(w http.ResponseWriter, r *http.Request, status int, id int) error {
+	c, err := cd.Snapshot().getCardOrError(id)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusCreated {
+		w.Header().Set("Location", fmt.Sprintf("%s/cards/%d", apiPrefix, id))
+	}
+	return writeJSON(w, status, c)
+}
+
+type ApiSrsNext struct {
+	DueCount      int   `json:"due_count"`
+	LearningCount int   `json:"learning_count"`
+	Card          *Card `json:"card"`            // null when there is nothing to review
+	Facet         Facet `json:"facet,omitempty"` // The facet of the card to review: meaning or reading
+
+	// When there is nothing to review, when the next reviews are due
+	NextReviewHour  string `json:"next_review_hour,omitempty"` // HH:MM
+	NextReviewCount int    `json:"next_review_count,omitempty"`
+	OnVacation      bool   `json:"on_vacation,omitempty"` // Nothing is due until the vacation ends
+	HeldBack        int    `json:"held_back,omitempty"`   // Learned reviews due, but over the daily limit
+}
+
+// GET /srs/next
+// The card to review next. Like the SRS page, this moves the card to the back of the up next queue.
+func (cd *CardData) ApiSrsNextHandler(w http.ResponseWriter, r *http.Request) error {
+	srsData, s := cd.NextSrsCard()
+	next := ApiSrsNext{
+		DueCount:      srsData.DueCount,
+		LearningCount: srsData.LearningCount,
+		Card:          srsData.Card,
+		Facet:         srsData.Facet,
+		HeldBack:      srsData.HeldBack,
+	}
+	if srsData.Card == nil {
+		nextHour, err := s.GetNextScheduledHour()
+		if err != nil {
+			return err
+		}
+		next.NextReviewHour = nextHour.NextHour
+		next.NextReviewCount = nextHour.NumberDue
+		next.OnVacation = nextHour.OnVacation
+	}
+	return writeJSON(w, http.StatusOK, next)
+}
+
+type ApiAnswer struct {
+	CardID       int    `json:"card_id"`
+	Facet        string `json:"facet"`         // meaning or reading. Optional, defaults to meaning.
+	Grade        string `json:"grade"`         // again, hard, good or easy
+	ResponseTime int    `json:"response_time"` // Milliseconds the card was shown for. Optional.
+}
+
+type ApiAnswerResult struct {
+	Answered      bool          `json:"answered"` // False if the facet wasn't due, so the answer was ignored
+	Facet         Facet         `json:"facet"`
+	PreviousStage LearningStage `json:"previous_stage"` // Of the facet
+	NewStage      LearningStage `json:"new_stage"`      // Of the facet
+	Leech         bool          `json:"leech"`          // True if the facet has become a leech. See the -srs-leech-action setting.
+	Card          *Card         `json:"card"`
+}
+
+// POST /srs/answers
+func (cd *CardData) ApiSrsAnswerHandler(w http.ResponseWriter, r *http.Request) error {
+	var a ApiAnswer
+	err := readJSON(w, r, &a)
+	if err != nil {
+		return err
+	}
+	g, err := ParseGrade(a.Grade)
+	if err != nil {
+		return httpError(http.StatusBadRequest, err)
+	}
+	f, err := ParseFacet(a.Facet)
+	if err != nil {
+		return httpError(http.StatusBadRequest, err)
+	}
+	if a.ResponseTime < 0 {
+		a.ResponseTime = 0
+	}
+
+	result, err := cd.AnswerCardInSession(clientID(r), a.CardID, f, g, a.ResponseTime)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, ApiAnswerResult{
+		Answered:      result.Answered,
+		Facet:         result.Facet,
+		PreviousStage: result.PreviousStage,
+		NewStage:      result.NewStage,
+		Leech:         result.Leech,
+		Card:          result.Card,
+	})
+}
+
+type ApiUndoResult struct {
+	Facet Facet  `json:"facet"`
+	Grade string `json:"grade"` // The answer that was undone
+	Card  *Card  `json:"card"`
+}
+
+// POST /srs/undo
+// Undo the last answer given with the same token
+func (cd *CardData) ApiSrsUndoHandler(w http.ResponseWriter, r *http.Request) error {
+	result, err := cd.UndoAnswer(clientID(r))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, ApiUndoResult{
+		Facet: result.Facet,
+		Grade: result.Grade.String(),
+		Card:  result.Card,
+	})
+}
+
+type ApiCheck struct {
+	CardID int    `json:"card_id"`
+	Facet  string `json:"facet"`  // meaning or reading. Optional, defaults to meaning.
+	Answer string `json:"answer"` // As typed. Readings can be in romaji or kana.
+}
+
+type ApiCheckResult struct {
+	Verdict  AnswerVerdict `json:"verdict"`           // correct, close, other_reading or incorrect
+	Answer   string        `json:"answer"`            // The answer as compared, in hiragana for readings
+	Expected string        `json:"expected"`          // The answer matched or closest to, or the primary answer
+	Message  string        `json:"message,omitempty"` // e.g. "Did you mean ...?"
+}
+
+// POST /srs/checks
+// Check a typed answer. This doesn't grade the card, so the answer still needs to be posted to /srs/answers.
+func (cd *CardData) ApiSrsCheckHandler(w http.ResponseWriter, r *http.Request) error {
+	var a ApiCheck
+	err := readJSON(w, r, &a)
+	if err != nil {
+		return err
+	}
+	f, err := ParseFacet(a.Facet)
+	if err != nil {
+		return httpError(http.StatusBadRequest, err)
+	}
+	if strings.TrimSpace(a.Answer) == "" {
+		return httpError(http.StatusBadRequest, errors.New("answer is empty"))
+	}
+
+	check, err := cd.CheckAnswer(a.CardID, f, a.Answer)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, ApiCheckResult{
+		Verdict:  check.Verdict,
+		Answer:   check.Answer,
+		Expected: check.Expected,
+		Message:  check.Message,
+	})
+}
+
+func (cd *CardData) ApiUpNextHandler(w http.ResponseWriter, r *http.Request) error {
+	upNext := cd.Snapshot().GetUpNextCards()
+	if upNext == nil {
+		upNext = []*Card{}
+	}
+	return writeJSON(w, http.StatusOK, upNext)
+}
+
+type ApiUpNextAdd struct {
+	Count  int `json:"count,omitempty"`   // Add this many new cards
+	CardID int `json:"card_id,omitempty"` // Or add this card
+}
+
+// POST /upnext
+// Add new cards to the up next queue. Responds with the queue.
+func (cd *CardData) ApiUpNextAddHandler(w http.ResponseWriter, r *http.Request) error {
+	var a ApiUpNextAdd
+	err := readJSON(w, r, &a)
+	if err == nil && (a.Count <= 0) == (a.CardID <= 0) {
+		err = httpError(http.StatusBadRequest, errors.New("give either a positive count or a card_id"))
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.CardID > 0 {
+		err = cd.AddToUpNextQueue(a.CardID)
+	} else {
+		err = cd.QueueUpNextCards(a.Count)
+	}
+	if err != nil {
+		return err
+	}
+	return cd.ApiUpNextHandler(w, r)
+}
+
+type ApiScheduleEntry struct {
+	Time  string `json:"time"`
+	Count int    `json:"count"`
+}
+
+func (cd *CardData) ApiScheduleHandler(w http.ResponseWriter, r *http.Request) error {
+	schedule := []ApiScheduleEntry{}
+	data, err := cd.Snapshot().GetScheduleData()
+	if err != nil {
+		return err
+	}
+	for _, e := range data {
+		schedule = append(schedule, ApiScheduleEntry{Time: e.Time, Count: e.Count})
+	}
+	return writeJSON(w, http.StatusOK, schedule)
+}
+
+type ApiVacation struct {
+	OnVacation bool             `json:"on_vacation"`
+	Vacations  []VacationPeriod `json:"vacations"` // Oldest first. The current vacation has no end.
+}
+
+// GET /vacation
+func (cd *CardData) ApiVacationHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	vacation := ApiVacation{
+		OnVacation: s.CurrentVacation() != nil,
+		Vacations:  append([]VacationPeriod{}, s.Vacations...),
+	}
+	return writeJSON(w, http.StatusOK, vacation)
+}
+
+// POST /vacation
+// Go on vacation. Nothing is due until it ends.
+func (cd *CardData) ApiVacationStartHandler(w http.ResponseWriter, r *http.Request) error {
+	err := cd.StartVacation()
+	if err != nil {
+		return err
+	}
+	return cd.ApiVacationHandler(w, r)
+}
+
+// DELETE /vacation
+// End the vacation, moving every scheduled review later by its length
+func (cd *CardData) ApiVacationEndHandler(w http.ResponseWriter, r *http.Request) error {
+	_, err := cd.EndVacation()
+	if err != nil {
+		return err
+	}
+	return cd.ApiVacationHandler(w, r)
+}
+
+type ApiDictionaryEntry struct {
+	ID              int                       `json:"id"`
+	Expressions     []string                  `json:"expressions"`
+	Readings        []string                  `json:"readings"`
+	Definitions     []ApiDictionaryDefinition `json:"definitions"`
+	MatchingCardIDs []int                     `json:"matching_card_ids"`
+}
+
+type ApiDictionaryDefinition struct {
+	PartsOfSpeech []string `json:"parts_of_speech"`
+	Definitions   []string `json:"definitions"`
+}
+
+func newApiDictionaryEntry(e DictionaryEntry) ApiDictionaryEntry {
+	a := ApiDictionaryEntry{
+		ID:              e.ID,
+		Expressions:     e.Expressions,
+		Readings:        e.Readings,
+		Definitions:     []ApiDictionaryDefinition{},
+		MatchingCardIDs: []int{},
+	}
+	for _, d := range e.Definitions {
+		a.Definitions = append(a.Definitions, ApiDictionaryDefinition{PartsOfSpeech: d.PartsOfSpeech, Definitions: d.Definitions})
+	}
+	for _, c := range e.MatchingCards {
+		a.MatchingCardIDs = append(a.MatchingCardIDs, c.ID)
+	}
+	return a
+}
+
+// GET /dictionary?q=
+// Search the dictionary by word, reading (kana or romaji) or English meaning
+func (cd *CardData) ApiDictionarySearchHandler(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return httpError(http.StatusBadRequest, errors.New("q is required"))
+	}
+
+	results, err := SearchDictionary(cd.Snapshot(), q)
+	if err != nil {
+		return err
+	}
+	entries := []ApiDictionaryEntry{}
+	for _, e := range results.DictSearchResults {
+		entries = append(entries, newApiDictionaryEntry(e))
+	}
+	return writeJSON(w, http.StatusOK, entries)
+}
+
+// POST /dictionary/{id}/card
+// Add a dictionary entry as a new vocabulary card
+func (cd *CardData) ApiDictionaryAddCardHandler(w http.ResponseWriter, r *http.Request) error {
+	c, err := cd.Snapshot().NewCardFromDictionary(apiID(r))
+	if err != nil {
+		return err
+	}
+	id, err := cd.AddNewCard(c)
+	if err != nil {
+		return err
+	}
+	return cd.writeCard(w, r, http.StatusCreated, id)
+}
+
+type ApiTextAnalysis struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Text   string     `json:"text,omitempty"`
+	Tokens []ApiToken `json:"tokens,omitempty"`
+}
+
+type ApiToken struct {
+	Surface           string   `json:"surface"`
+	BaseForm          string   `json:"base_form"`
+	Pronunciation     string   `json:"pronunciation"`
+	PartsOfSpeech     []string `json:"parts_of_speech"`
+	IsGrammar         bool     `json:"is_grammar"`
+	CardID            int      `json:"card_id,omitempty"` // The matching card, if any
+	DictionaryEntries []int    `json:"dictionary_entry_ids,omitempty"`
+}
+
+// GET /textanalyses
+// Every text analysis, sorted by name, without the texts
+func (cd *CardData) ApiTextAnalysesHandler(w http.ResponseWriter, r *http.Request) error {
+	taList, err := cd.ListTextAnalyses()
+	if err != nil {
+		return err
+	}
+	list := []ApiTextAnalysis{}
+	for _, ta := range taList {
+		list = append(list, ApiTextAnalysis{ID: ta.ID, Name: ta.Name})
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+type ApiTextAnalysisNew struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+func (cd *CardData) ApiTextAnalysisCreateHandler(w http.ResponseWriter, r *http.Request) error {
+	var n ApiTextAnalysisNew
+	err := readJSON(w, r, &n)
+	if err == nil && n.Text == "" {
+		err = httpError(http.StatusBadRequest, errors.New("text is required"))
+	}
+	if err != nil {
+		return err
+	}
+
+	ta, err := cd.NewTextAnalysis(n.Name, n.Text)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", apiPrefix+"/textanalyses/"+ta.ID)
+	return writeJSON(w, http.StatusCreated, ApiTextAnalysis{ID: ta.ID, Name: ta.Name, Text: ta.Text})
+}
+
+// GET /textanalyses/{id}
+// A text analysis with its text broken into tokens, each matched to a card or dictionary entries
+func (cd *CardData) ApiTextAnalysisHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	ta, err := s.GetTextAnalysis(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	err = ta.Analyse(s)
+	if err != nil {
+		return err
+	}
+
+	a := ApiTextAnalysis{ID: ta.ID, Name: ta.Name, Text: ta.Text, Tokens: []ApiToken{}}
+	for _, t := range ta.Tokens {
+		token := ApiToken{
+			Surface:       t.Surface,
+			BaseForm:      t.BaseForm,
+			Pronunciation: t.Pronunciation,
+			PartsOfSpeech: t.PartsOfSpeech,
+			IsGrammar:     t.IsGrammar,
+		}
+		if t.Card != nil {
+			token.CardID = t.Card.ID
+		}
+		for _, e := range t.DictionaryEntries {
+			token.DictionaryEntries = append(token.DictionaryEntries, e.ID)
+		}
+		a.Tokens = append(a.Tokens, token)
+	}
+	return writeJSON(w, http.StatusOK, a)
+}
+
+func (cd *CardData) ApiTextAnalysisDeleteHandler(w http.ResponseWriter, r *http.Request) error {
+	err := cd.DeleteTextAnalysis(mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type ApiHistoricalEntry struct {
+	Time       string `json:"time"` // RFC3339
+	Radicals   int    `json:"radicals"`
+	Kanji      int    `json:"kanji"`
+	Vocabulary int    `json:"vocabulary"`
+	Grammar    int    `json:"grammar"`
+	Vacation   bool   `json:"vacation"` // On vacation for some of the day
+}
+
+// GET /stats/historical
+// The number of cards of each type known, recorded once a day
+func (cd *CardData) ApiHistoricalStatsHandler(w http.ResponseWriter, r *http.Request) error {
+	data, err := cd.Snapshot().GetHistoricalData()
+	if err != nil {
+		return err
+	}
+	entries := []ApiHistoricalEntry{}
+	for _, e := range data.HistoricalDataEntries {
+		entries = append(entries, ApiHistoricalEntry{
+			Time:       e.DateTime,
+			Radicals:   e.RadicalsKnown,
+			Kanji:      e.KanjiKnown,
+			Vocabulary: e.VocabularyKnown,
+			Grammar:    e.GrammarKnown,
+			Vacation:   e.OnVacation,
+		})
+	}
+	return writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,191 @@
+package cards
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func createTestRouter(t *testing.T, n int) (*CardData, http.Handler) {
+	cd := createStoreCardData(t, n)
+	cd.StaticDir = "../../static"
+	cd.BackupDir = filepath.Join(cd.DataDir, "backup")
+	cd.SetupFuncMap()
+	err := cd.LoadTemplates()
+	if err != nil {
+		t.Fatalf("Error loading templates: %s", err)
+	}
+	h := NewRouter(cd, singleProfile(cd))
+	return cd, h
+}
+
+// Make an API request as a script would, with a bearer token rather than a CSRF token
+func apiRequest(h http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestApiCards(t *testing.T) {
+	_, h := createTestRouter(t, 3)
+
+	w := apiRequest(h, "GET", "/api/v1/cards", "")
+	var cards []Card
+	err := json.Unmarshal(w.Body.Bytes(), &cards)
+	if w.Code != http.StatusOK || err != nil || len(cards) != 3 {
+		t.Errorf("Expected 3 cards, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = apiRequest(h, "GET", "/api/v1/cards/99", "")
+	var apiErr ApiError
+	json.Unmarshal(w.Body.Bytes(), &apiErr)
+	if w.Code != http.StatusNotFound || apiErr.Error == "" {
+		t.Errorf("Expected a 404 JSON error, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/cards", `{"id": 1, "object": "vocabulary", "characters": "猫"}`)
+	var c Card
+	json.Unmarshal(w.Body.Bytes(), &c)
+	if w.Code != http.StatusCreated || c.ID == 1 || c.Characters != "猫" {
+		t.Errorf("Expected a new card to be created, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Location") != fmt.Sprintf("/api/v1/cards/%d", c.ID) {
+		t.Errorf("Expected the location of the new card, got %s", w.Header().Get("Location"))
+	}
+
+	w = apiRequest(h, "PUT", "/api/v1/cards/1", `{"characters": `)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid JSON, got %d", w.Code)
+	}
+	w = apiRequest(h, "PUT", "/api/v1/cards/1", `{"characters": "犬", "next_review_date": "tomorrow"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid date, got %d", w.Code)
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/cards/1/queue", "")
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for queueing a learned card, got %d", w.Code)
+	}
+
+	w = apiRequest(h, "DELETE", "/api/v1/cards/2", "")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 for deleting, got %d", w.Code)
+	}
+	w = apiRequest(h, "GET", "/api/v1/cards/2", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted card, got %d", w.Code)
+	}
+
+	w = apiRequest(h, "GET", "/api/v1/nothing", "")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a 404 JSON error for an unknown endpoint, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// Without a bearer token, a POST needs a CSRF token like any other
+	req := httptest.NewRequest("DELETE", "/api/v1/cards/1", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a bearer or CSRF token, got %d", w.Code)
+	}
+}
+
+func TestApiSrs(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+
+	w := apiRequest(h, "GET", "/api/v1/srs/next", "")
+	var next ApiSrsNext
+	json.Unmarshal(w.Body.Bytes(), &next)
+	if w.Code != http.StatusOK || next.Card == nil || next.DueCount != 2 {
+		t.Errorf("Expected a due card with 2 due, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": 1, "grade": "meh"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown grade, got %d", w.Code)
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": 1, "grade": "good", "response_time": 1500}`)
+	var result ApiAnswerResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || !result.Answered || result.Card.Interval != 96 {
+		t.Errorf("Expected the answer to double the interval, got %d %s", w.Code, w.Body.String())
+	}
+	if cd.Cards[1].Interval != 96 {
+		t.Errorf("Expected interval 96, got %d", cd.Cards[1].Interval)
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/upnext", `{}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 with neither a count nor a card, got %d", w.Code)
+	}
+	w = apiRequest(h, "POST", "/api/v1/upnext", `{"card_id": 2}`)
+	var upNext []Card
+	json.Unmarshal(w.Body.Bytes(), &upNext)
+	if w.Code != http.StatusOK || len(upNext) != 1 || upNext[0].ID != 2 {
+		t.Errorf("Expected card 2 to be up next, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestApiAnswerUnreviewableCard(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+	cd.Cards[1].Interval = 9600 // Burned
+	cd.Cards[1].NextReviewDate = ""
+	cd.Cards[2].LearningStage = Unavailable
+	cd.Cards[2].Interval = 0
+	cd.Cards[2].NextReviewDate = ""
+	cd.UpdateCardData()
+
+	for _, id := range []string{"1", "2"} {
+		w := apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": `+id+`, "grade": "good"}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status 409 answering card %s, got %d %s", id, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestApiConcurrentStatsAndEdits(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+	if err := cd.Snapshot().SaveHistoricalData(); err != nil {
+		t.Fatalf("Error saving historical data: %s", err)
+	}
+
+	// Read the stats while cards are being added
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if _, err := cd.AddNewCard(&Card{Object: "vocabulary", Characters: "犬"}); err != nil {
+				t.Errorf("Error adding card: %s", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			if w := apiRequest(h, "GET", "/api/v1/stats/historical", ""); w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d", w.Code)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/historicalstats", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d", w.Code)
+			}
+		}
+	}()
+	wg.Wait()
+}
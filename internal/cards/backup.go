@@ -0,0 +1,346 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backups are full copies of the cards file named cards-<RFC3339>.json.
+// One is taken on every start up, before every card is deleted, before a backup is restored,
+// and before each migration of the cards file (see schema.go).
+// After each backup the backup directory is pruned according to the RetentionPolicy.
+
+// RetentionPolicy decides which backups are kept.
+// A backup is kept if any of the rules wants to keep it.
+// The zero value keeps every backup.
+type RetentionPolicy struct {
+	KeepLast int // The most recent backups
+	Daily    int // The newest backup of each of the most recent days
+	Weekly   int // The newest backup of each of the most recent weeks
+	Monthly  int // The newest backup of each of the most recent months
+}
+
+var DefaultRetentionPolicy = RetentionPolicy{
+	KeepLast: 10,
+	Daily:    7,
+	Weekly:   4,
+	Monthly:  12,
+}
+
+func (p RetentionPolicy) IsZero() bool {
+	return p == RetentionPolicy{}
+}
+
+type Backup struct {
+	Name string
+	Path string
+	Time time.Time
+	Size int64
+}
+
+func (b Backup) SizeKiB() int64 {
+	return b.Size / 1024
+}
+
+const backupPrefix = "cards-"
+const backupSuffix = ".json"
+
+// Parse the time from a backup filename. Returns false if it isn't a backup.
+func parseBackupName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, false
+	}
+	ts := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+
+	// Backups taken before a migration have the version they were migrated from, e.g. cards-<time>-v0.json
+	if i := strings.LastIndex(ts, "-v"); i >= 0 {
+		if _, err := strconv.Atoi(ts[i+2:]); err == nil {
+			ts = ts[:i]
+		}
+	}
+
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// List the backups in a directory, newest first
+func ListBackups(dir string) ([]Backup, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, f := range files {
+		t, ok := parseBackupName(f.Name())
+		if !ok || f.IsDir() {
+			continue
+		}
+		backups = append(backups, Backup{
+			Name: f.Name(),
+			Path: filepath.Join(dir, f.Name()),
+			Time: t,
+			Size: f.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+func (cd *CardData) ListBackups() ([]Backup, error) {
+	return ListBackups(cd.BackupDir)
+}
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Find a backup by name. Only names returned by ListBackups are accepted.
+func (cd *CardData) GetBackup(name string) (Backup, error) {
+	backups, err := cd.ListBackups()
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, b := range backups {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("backup %q: %w", name, ErrBackupNotFound)
+}
+
+// Work out which backups the policy keeps. backups must be sorted newest first.
+func (p RetentionPolicy) Keep(backups []Backup) map[string]bool {
+	keep := make(map[string]bool)
+	if p.IsZero() {
+		for _, b := range backups {
+			keep[b.Name] = true
+		}
+		return keep
+	}
+
+	for i := 0; i < len(backups) && i < p.KeepLast; i++ {
+		keep[backups[i].Name] = true
+	}
+
+	// Keep the newest backup in each of the most recent n periods
+	keepPeriods := func(n int, period func(t time.Time) string) {
+		seen := make(map[string]bool)
+		for _, b := range backups {
+			if len(seen) >= n {
+				return
+			}
+			key := period(b.Time.Local())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[b.Name] = true
+		}
+	}
+	keepPeriods(p.Daily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPeriods(p.Weekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	keepPeriods(p.Monthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	return keep
+}
+
+// Delete the backups that the policy doesn't keep. Returns the names of the deleted backups.
+func PruneBackups(dir string, p RetentionPolicy) ([]string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := p.Keep(backups)
+	var pruned []string
+	for _, b := range backups {
+		if keep[b.Name] {
+			continue
+		}
+		err := os.Remove(b.Path)
+		if err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, b.Name)
+	}
+	return pruned, nil
+}
+
+func (cd *CardData) PruneBackups() {
+	pruned, err := PruneBackups(cd.BackupDir, cd.BackupRetention)
+	if err != nil {
+		log.Printf("Error pruning backups: %s", err)
+	}
+	if len(pruned) > 0 {
+		log.Printf("Pruned %d old backups", len(pruned))
+	}
+}
+
+func ReadBackup(b Backup) (map[int]*Card, error) {
+	return ReadCardsFile(b.Path)
+}
+
+// BackupDiff summarises what restoring a backup would change
+type BackupDiff struct {
+	Backup   Backup
+	Restored []*Card // Cards in the backup that have since been deleted
+	Lost     []*Card // Cards that have been added since the backup
+	Changed  []BackupCardChange
+
+	// Number of cards in each learning stage, now and in the backup
+	CurrentStages map[string]int
+	BackupStages  map[string]int
+}
+
+type BackupCardChange struct {
+	Current *Card
+	Backup  *Card
+}
+
+func DiffCards(current map[int]*Card, backup map[int]*Card) BackupDiff {
+	diff := BackupDiff{
+		CurrentStages: make(map[string]int),
+		BackupStages:  make(map[string]int),
+	}
+
+	for id, c := range current {
+		diff.CurrentStages[c.GetLearningStageString()]++
+
+		b, ok := backup[id]
+		if !ok {
+			diff.Lost = append(diff.Lost, c)
+			continue
+		}
+
+		cj, _ := json.Marshal(c)
+		bj, _ := json.Marshal(b)
+		if !bytes.Equal(cj, bj) {
+			diff.Changed = append(diff.Changed, BackupCardChange{Current: c, Backup: b})
+		}
+	}
+	for id, b := range backup {
+		diff.BackupStages[b.GetLearningStageString()]++
+
+		if _, ok := current[id]; !ok {
+			diff.Restored = append(diff.Restored, b)
+		}
+	}
+
+	diff.Restored = sortCardsById(diff.Restored)
+	diff.Lost = sortCardsById(diff.Lost)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Current.ID < diff.Changed[j].Current.ID
+	})
+
+	return diff
+}
+
+// Compare the current cards with a backup
+func (cd *CardData) DiffBackup(name string) (BackupDiff, error) {
+	b, err := cd.GetBackup(name)
+	if err != nil {
+		return BackupDiff{}, err
+	}
+	backupCards, err := ReadBackup(b)
+	if err != nil {
+		return BackupDiff{}, err
+	}
+
+	diff := DiffCards(cd.Snapshot().Cards, backupCards)
+	diff.Backup = b
+	return diff, nil
+}
+
+// Replace the cards with those in a backup.
+// The current cards are backed up first, so a restore can itself be undone.
+// Other profiles get the content of the backup, but keep their own progress.
+func (cd *CardData) RestoreBackup(name string) error {
+	b, err := cd.GetBackup(name)
+	if err != nil {
+		return err
+	}
+	backupCards, err := ReadBackup(b)
+	if err != nil {
+		return err
+	}
+
+	// The restored cards belong to cd, so other profiles copy from their own copy
+	content := make(map[int]*Card, len(backupCards))
+	for id, c := range backupCards {
+		content[id] = c.Content()
+	}
+
+	return cd.updateContent(func(p *CardData) error {
+		err := p.BackupCardMap()
+		if err != nil {
+			return err
+		}
+
+		if p != cd {
+			p.replaceContent(content)
+			return nil
+		}
+		log.Printf("Restoring backup %s", b.Name)
+		cd.Cards = backupCards
+		cd.UpNext = nil
+		return nil
+	})
+}
+
+type BackupStageRow struct {
+	Stage   string
+	Current int
+	Backup  int
+}
+
+// Learning stage counts in learning stage order, for display
+func (d BackupDiff) StageRows() []BackupStageRow {
+	var rows []BackupStageRow
+	for _, ls := range []LearningStage{Unavailable, Available, QueuedToLearn, UpNext, Learning, Learned, Burned} {
+		s := LearningStageToString(ls)
+		rows = append(rows, BackupStageRow{
+			Stage:   s,
+			Current: d.CurrentStages[s],
+			Backup:  d.BackupStages[s],
+		})
+	}
+	return rows
+}
+
+func (d BackupDiff) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Restoring %s will:\n", d.Backup.Name)
+	fmt.Fprintf(&sb, "  restore %d deleted cards\n", len(d.Restored))
+	fmt.Fprintf(&sb, "  remove %d cards added since the backup\n", len(d.Lost))
+	fmt.Fprintf(&sb, "  revert %d changed cards\n", len(d.Changed))
+	sb.WriteString("Learning stages (now -> backup):\n")
+	for _, row := range d.StageRows() {
+		fmt.Fprintf(&sb, "  %-16s %6d -> %d\n", row.Stage, row.Current, row.Backup)
+	}
+	return sb.String()
+}
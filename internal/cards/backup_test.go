@@ -0,0 +1,154 @@
+package cards
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Backups every 6 hours for 90 days, newest first
+func createBackupList() []Backup {
+	var backups []Backup
+	t := time.Date(2023, 6, 30, 18, 0, 0, 0, time.Local)
+	for i := 0; i < 90*4; i++ {
+		name := backupPrefix + t.Format(time.RFC3339) + backupSuffix
+		backups = append(backups, Backup{Name: name, Time: t})
+		t = t.Add(-6 * time.Hour)
+	}
+	return backups
+}
+
+func TestRetentionPolicyKeep(t *testing.T) {
+	backups := createBackupList()
+
+	keep := RetentionPolicy{}.Keep(backups)
+	if len(keep) != len(backups) {
+		t.Errorf("Expected the zero policy to keep all %d backups, got %d", len(backups), len(keep))
+	}
+
+	keep = RetentionPolicy{KeepLast: 3}.Keep(backups)
+	if len(keep) != 3 || !keep[backups[0].Name] || !keep[backups[2].Name] {
+		t.Errorf("Expected the newest 3 backups to be kept, got %v", keep)
+	}
+
+	// The newest backup of each day. The first 4 backups are all on the newest day.
+	keep = RetentionPolicy{Daily: 2}.Keep(backups)
+	if len(keep) != 2 || !keep[backups[0].Name] || !keep[backups[4].Name] {
+		t.Errorf("Expected the newest backup of the 2 newest days to be kept, got %v", keep)
+	}
+
+	keep = RetentionPolicy{Monthly: 12}.Keep(backups)
+	if len(keep) != 3 {
+		t.Errorf("Expected one backup for each of the 3 months, got %d", len(keep))
+	}
+
+	// Rules overlap, so the newest backup is only counted once
+	keep = RetentionPolicy{KeepLast: 1, Daily: 1, Weekly: 1, Monthly: 1}.Keep(backups)
+	if len(keep) != 1 {
+		t.Errorf("Expected 1 backup, got %d", len(keep))
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	for i, b := range createBackupList()[:20] {
+		err := ioutil.WriteFile(filepath.Join(dir, b.Name), []byte("{}"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Files that aren't backups are never touched
+		if i == 0 {
+			ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte(""), 0644)
+		}
+	}
+
+	pruned, err := PruneBackups(dir, RetentionPolicy{KeepLast: 5})
+	if err != nil {
+		t.Fatalf("Error pruning backups: %s", err)
+	}
+	if len(pruned) != 15 {
+		t.Errorf("Expected 15 backups to be pruned, got %d", len(pruned))
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatalf("Error listing backups: %s", err)
+	}
+	if len(backups) != 5 {
+		t.Errorf("Expected 5 backups to remain, got %d", len(backups))
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 6 {
+		t.Errorf("Expected 6 files to remain, got %d", len(files))
+	}
+}
+
+func TestDiffAndRestoreBackup(t *testing.T) {
+	cd := createStoreCardData(t, 3)
+	cd.BackupDir = filepath.Join(cd.DataDir, "backup")
+	err := cd.BackupCardMap()
+	if err != nil {
+		t.Fatalf("Error backing up cards: %s", err)
+	}
+	backups, err := cd.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d (%v)", len(backups), err)
+	}
+	name := backups[0].Name
+
+	// Change a card, delete a card and add a card
+	_, err = cd.AnswerCard(1, MeaningFacet, Good, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd.Cards[2].Interval = 1000
+	cd.Cards[4] = CreateCard(4, 48, 0, "2020-01-01T00:00:00Z")
+	delete(cd.Cards, 3)
+
+	diff, err := cd.DiffBackup(name)
+	if err != nil {
+		t.Fatalf("Error diffing backup: %s", err)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].Current.ID != 1 || diff.Changed[1].Current.ID != 2 {
+		t.Errorf("Expected cards 1 and 2 to be changed, got %+v", diff.Changed)
+	}
+	if len(diff.Restored) != 1 || diff.Restored[0].ID != 3 {
+		t.Errorf("Expected card 3 to be restored, got %+v", diff.Restored)
+	}
+	if len(diff.Lost) != 1 || diff.Lost[0].ID != 4 {
+		t.Errorf("Expected card 4 to be lost, got %+v", diff.Lost)
+	}
+
+	// Backups made within the same second would overwrite each other
+	time.Sleep(time.Second)
+
+	err = cd.RestoreBackup(name)
+	if err != nil {
+		t.Fatalf("Error restoring backup: %s", err)
+	}
+	if cd.Cards[1].Interval != 48 || cd.Cards[2].Interval != 48 {
+		t.Errorf("Expected intervals to be restored to 48, got %d and %d", cd.Cards[1].Interval, cd.Cards[2].Interval)
+	}
+	if _, ok := cd.Cards[3]; !ok {
+		t.Errorf("Expected card 3 to be restored")
+	}
+	if _, ok := cd.Cards[4]; ok {
+		t.Errorf("Expected card 4 to be removed")
+	}
+	cards := loadSavedCards(t, cd)
+	if len(cards) != 3 || cards[1].Interval != 48 {
+		t.Errorf("Expected the restored cards to be saved, got %d cards", len(cards))
+	}
+
+	// The cards were backed up before restoring
+	backups, _ = cd.ListBackups()
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups after restoring, got %d", len(backups))
+	}
+
+	_, err = cd.DiffBackup("../cards.json")
+	if err == nil {
+		t.Errorf("Expected an error for a name that isn't a backup")
+	}
+}
@@ -73,6 +73,7 @@ type Card struct {
 	Volume    string     `json:"volume"`
 	Page      string     `json:"page"`
 
+	// Progress. Saved in the progress file rather than the cards file. See progress.go.
 	Interval           int    `json:"interval"`          // Hours until next review
 	LearningInterval   int    `json:"learning_interval"` // Hours until next review when in learning stage
 	NextReviewDate     string `json:"next_review_date"`  // RFC3339 date string
@@ -80,16 +81,29 @@ type Card struct {
 	TotalTimesCorrect  int    `json:"total_times_correct"`
 	QueuedToLearn      bool   `json:"queued_to_learn"`
 
+	// FSRS memory state. Only used by the FSRS scheduler.
+	Stability      float64 `json:"stability"`        // Days until recall probability drops to 90%
+	Difficulty     float64 `json:"difficulty"`       // 1 (easy) - 10 (hard)
+	LastReviewDate string  `json:"last_review_date"` // RFC3339 date string
+
 	LearningStage LearningStage `json:"learning_stage"` // 0 = Unavailable, 1 = Available, 2 = Learning, 3 = Learned, 4 = Burned
+	Lapses        int           `json:"lapses"`         // Times in a row a learned card has been forgotten. See leech.go.
+
+	// The fields above are the meaning facet. See facet.go.
+	ReadingReview *FacetReview `json:"reading_review,omitempty"` // Nil until the facets are first answered separately
 
 	Tags []string `json:"tags"`
 
+	// Your own answers to the meaning, kept with the progress so they survive content updates. See answer.go.
+	UserSynonyms   []string `json:"user_synonyms,omitempty"`   // Accepted as well as the card's meanings
+	BlockedAnswers []string `json:"blocked_answers,omitempty"` // Rejected, even if they are close to a meaning
+
 	// Below is for html output
 	LearningStageString string `json:"-"` // Unavailable, Available, Learning, Learned, Burned
 }
 
 func (c *Card) UpdateLearningStage(cd *CardData) {
-	if c.Interval > 8760 {
+	if c.Interval > cd.GetScheduler().Settings().BurnInterval {
 		c.LearningStage = Burned
 		c.LearningStageString = LearningStageToString(c.LearningStage)
 		return
@@ -173,11 +187,24 @@ func (c *Card) GetPartsOfSpeech() []string {
 }
 
 func (c *Card) GetReviewPerformance() float64 {
-	if c.TotalTimesReviewed == 0 {
+	reviewed, correct := c.TotalTimesReviewed, c.TotalTimesCorrect
+	if c.ReadingReview != nil {
+		reviewed += c.ReadingReview.TotalTimesReviewed
+		correct += c.ReadingReview.TotalTimesCorrect
+	}
+	if reviewed == 0 {
 		return 0
 	}
 
-	return float64(c.TotalTimesCorrect) / float64(c.TotalTimesReviewed)
+	return float64(correct) / float64(reviewed)
+}
+
+// Hours until the next review, for whichever stage the card is in
+func (c *Card) CurrentInterval() int {
+	if c.LearningStage == Learning {
+		return c.LearningInterval
+	}
+	return c.Interval
 }
 
 func (c *Card) IncrementReviewCount() {
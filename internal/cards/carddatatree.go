@@ -91,8 +91,7 @@ func customHtmlTagsToSpan(str string) string {
 
 func (dt *CardDataTree) IsAllChildrenLearned() bool {
 	for _, child := range dt.ComponentSubjects {
-		if child.Card.LearningStage != Learned &&
-			child.Card.LearningStage != Burned {
+		if !child.Card.IsLearned() {
 			return false
 		}
 	}
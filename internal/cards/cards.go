@@ -1,6 +1,7 @@
 package cards
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"foosoft.net/projects/jmdict"
@@ -20,9 +22,20 @@ import (
 type CardData struct {
 	CardsFile          string
 	DataDir            string
+	ProfileDir         string // Directory for this profile's progress and history. Defaults to DataDir.
+	Profile            string // Name of the user this card data belongs to. Empty in single user mode.
+	Library            *Library
 	BackupDir          string
-	StaticDir          string
+	StaticDir          string // Only used in dev mode. The static files are embedded otherwise.
+	Dev                bool   // Read templates and static files from StaticDir on every request
+	DictionaryFile     string // JMdict file. Defaults to JMdict_e in DataDir.
+	KanjiFrequencyDir  string // Kanji frequency lists. Defaults to kanji_frequencies in DataDir.
+	Scheduler          Scheduler
+	BackupRetention    RetentionPolicy
+	DayBoundary        DayBoundary // When each study day starts. See days.go.
+	Clock              Clock       // Defaults to the wall clock. See clock.go.
 	UpNext             []*Card
+	Vacations          []VacationPeriod // Oldest first. See vacation.go.
 	FuncMap            map[string]interface{}
 	Cards              map[int]*Card
 	Dictionary         jmdict.Jmdict
@@ -33,30 +46,97 @@ type CardData struct {
 	DictionaryReadingMap         map[string][]*jmdict.JmdictEntry // Reading (in hiragana) -> JmdictEntry
 	DictionaryNonKanjiReadingMap map[string][]*jmdict.JmdictEntry // Reading -> JmdictEntry
 	DictionaryMeaningMap         map[string][]*jmdict.JmdictEntry // Meaning -> JmdictEntry
+
+	mu          sync.RWMutex   // Guards Cards and UpNext. See store.go.
+	readOnly    bool           // Set on snapshots, which must never be saved
+	savedImages map[int][]byte // JSON of each card as of the last save. See journal.go.
+	templates   templateSet    // Parsed once by LoadTemplates. See templates.go.
+
+	savedContent     []byte               // The cards file as of the last save. See progress.go.
+	orphanedProgress map[int]CardProgress // Progress for cards that are not in the cards file
+
+	undo map[string]*undoStack // Undo stacks by client. Guarded by mu. See undo.go.
+
+	today     *dayLog   // Today's answers, for the daily limits. See days.go.
+	todayOnce sync.Once // Creates today
 }
 
 func (cd *CardData) LoadCardJson() {
 	log.Println("Loading card data...")
 
-	cardsData := make(map[int]*Card)
 	cardsJson, err := ioutil.ReadFile(cd.CardsFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = json.Unmarshal(cardsJson, &cardsData)
+	// Upgrade old cards files. The upgraded file is saved below.
+	cardsJson, fromVersion, err := MigrateCardsFile(cardsJson, cd.backupBeforeMigration)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if fromVersion != CardsFileVersion {
+		log.Printf("Migrated cards file from version %d to %d", fromVersion, CardsFileVersion)
+	}
 
+	cardsData, err := ParseCardsFile(cardsJson)
+	if err != nil {
+		log.Fatal(err)
+	}
 	cd.Cards = cardsData
 
+	cd.Vacations, err = ReadVacationFile(cd.VacationFile())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Cards files from before progress was split out carry their own progress,
+	// which is used until the first progress file is saved.
+	// Profiles never take it. The first user was given it by SeedProfile, and anyone else starts afresh.
+	progress, err := ReadProgressFile(cd.ProgressFile())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if progress == nil && cd.Profile != "" {
+		log.Printf("No progress file found at %s, starting afresh", cd.ProgressFile())
+		progress = make(map[int]CardProgress)
+	}
+	if progress != nil {
+		cd.applyProgress(progress)
+	} else {
+		log.Printf("No progress file found at %s, using progress from the cards file", cd.ProgressFile())
+	}
+
+	// Recover any changes that were made but not saved before the last shut down
+	replayed, err := ReplayJournal(cd.JournalFile(), cardsData)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if replayed > 0 {
+		log.Printf("Replayed %d journal records", replayed)
+	}
+
 	log.Printf("Loaded %d cards", len(cd.Cards))
 
 	// Backup cards on start up, then update them
-	cd.BackupCardMap()
+	err = cd.BackupCardMap()
+	if err != nil {
+		log.Fatal(err)
+	}
 	cd.UpdateCardData()
-	cd.SaveCardMap()
+	err = cd.SaveCardMap()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The journal has been applied and saved, so start a fresh one
+	err = os.Remove(cd.JournalFile())
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	cd.savedImages, err = cd.cardImages()
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 func (cd *CardData) UpdateCardData() {
@@ -76,9 +156,9 @@ func (cd *CardData) UpdateCardData() {
 		}
 	}
 
-	// Check the UpNext list and remove any cards that are no longer in the UpNext stage
+	// Check the UpNext list and remove any cards that no longer have a facet in the UpNext stage
 	for i := 0; i < len(cd.UpNext); i++ {
-		if cd.UpNext[i].LearningStage != UpNext {
+		if !cd.UpNext[i].HasFacetInStage(UpNext) {
 			cd.UpNext = append(cd.UpNext[:i], cd.UpNext[i+1:]...)
 			i--
 		}
@@ -87,37 +167,98 @@ func (cd *CardData) UpdateCardData() {
 	log.Println("Updated cards")
 }
 
-func (cd *CardData) BackupCardMap() {
+func (cd *CardData) ensureBackupDir() error {
 	// If the backup directory doesn't exist, create it
 	if _, err := os.Stat(cd.BackupDir); os.IsNotExist(err) {
 		log.Printf("Creating backup directory %s", cd.BackupDir)
 		err := os.Mkdir(cd.BackupDir, 0755)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 	}
+	return nil
+}
+
+func (cd *CardData) BackupCardMap() error {
+	err := cd.ensureBackupDir()
+	if err != nil {
+		return err
+	}
 
 	t := time.Now()
 	backupFilename := filepath.Join(cd.BackupDir, "cards-"+t.Format(time.RFC3339)+".json")
 	log.Printf("Backing up cards to %s", backupFilename)
-	cd.SaveCardMapToFilename(backupFilename)
+	err = cd.SaveCardMapToFilename(backupFilename)
+	if err != nil {
+		return err
+	}
+
+	cd.PruneBackups()
+	return nil
 }
 
-func (cd *CardData) SaveCardMap() {
+// Save the content to the cards file and the progress to the progress file
+func (cd *CardData) SaveCardMap() error {
 	log.Println("Saving cards")
-	cd.SaveCardMapToFilename(cd.CardsFile)
+	err := cd.saveContent()
+	if err != nil {
+		return err
+	}
+	return cd.saveProgress()
 }
 
-func (cd *CardData) SaveCardMapToFilename(path string) {
-	cardJson, err := json.Marshal(cd.Cards)
+// Save the cards with their progress to a single file. Used for backups.
+func (cd *CardData) SaveCardMapToFilename(path string) error {
+	cardJson, err := MarshalCardsFile(cd.Cards)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	return writeFileAtomic(path, cardJson)
+}
+
+// Write a file so that a crash leaves either the old contents or the new contents, never a mix.
+// The data is written to a temporary file in the same directory, fsynced, then renamed over the old file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
 	}
+	// Clean up the temporary file if anything goes wrong. Does nothing once it has been renamed.
+	defer os.Remove(tmp.Name())
 
-	err = ioutil.WriteFile(path, cardJson, 0644)
+	_, err = tmp.Write(data)
 	if err != nil {
-		log.Fatal(err)
+		tmp.Close()
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
 	}
+
+	// Sync the directory so the rename itself survives a crash
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
 type HistoricalData struct {
@@ -138,24 +279,32 @@ type HistoricalDataEntry struct {
 	KanjiKnown      int
 	VocabularyKnown int
 	GrammarKnown    int
+	OnVacation      bool
 }
 
-func (cd *CardData) GetHistoricalData() HistoricalData {
+func (cd *CardData) GetHistoricalData() (HistoricalData, error) {
 	// Load historical data csv
 	historicalData := HistoricalData{}
-	historicalDataFile, err := os.Open(filepath.Join(cd.DataDir, "historical-data.csv"))
+	historicalDataFile, err := os.Open(cd.HistoricalDataFile())
+	if os.IsNotExist(err) {
+		// Nothing has been recorded yet
+		return historicalData, nil
+	}
 	if err != nil {
-		log.Fatal(err)
+		return historicalData, err
 	}
 	defer historicalDataFile.Close()
 
 	historicalDataCsv := csv.NewReader(historicalDataFile)
 	historicalDataCsv.Comma = ','
+	// Lines from before vacations were recorded have one less field
+	historicalDataCsv.FieldsPerRecord = -1
 
-	radicalMax := 0
-	kanjiMax := 0
-	vocabularyMax := 0
-	grammarMax := 0
+	// The bars are scaled by the maxes, so they start at 1 to avoid dividing by zero before anything is known
+	radicalMax := 1
+	kanjiMax := 1
+	vocabularyMax := 1
+	grammarMax := 1
 
 	for {
 		record, err := historicalDataCsv.Read()
@@ -163,7 +312,10 @@ func (cd *CardData) GetHistoricalData() HistoricalData {
 			break
 		}
 		if err != nil {
-			log.Fatal(err)
+			return historicalData, err
+		}
+		if len(record) < 5 {
+			return historicalData, fmt.Errorf("%s has a line with %d fields, expected at least 5", cd.HistoricalDataFile(), len(record))
 		}
 
 		dateTime := record[0]
@@ -171,6 +323,7 @@ func (cd *CardData) GetHistoricalData() HistoricalData {
 		kanjiKnown, _ := strconv.Atoi(record[2])
 		vocabularyKnown, _ := strconv.Atoi(record[3])
 		grammarKnown, _ := strconv.Atoi(record[4])
+		onVacation := len(record) > 5 && record[5] == "1"
 
 		if radicalsKnown > radicalMax {
 			radicalMax = radicalsKnown
@@ -191,6 +344,7 @@ func (cd *CardData) GetHistoricalData() HistoricalData {
 			KanjiKnown:      kanjiKnown,
 			VocabularyKnown: vocabularyKnown,
 			GrammarKnown:    grammarKnown,
+			OnVacation:      onVacation,
 		}
 
 		historicalData.HistoricalDataEntries = append(historicalData.HistoricalDataEntries, &historicalDataEntry)
@@ -224,12 +378,13 @@ func (cd *CardData) GetHistoricalData() HistoricalData {
 	historicalData.VocabularyCount = vocabularyCount
 	historicalData.GrammarCount = grammarCount
 
-	return historicalData
+	return historicalData, nil
 }
 
-func (cd *CardData) SaveHistoricalData() {
+func (cd *CardData) SaveHistoricalData() error {
 	// Gather historical data
-	dateTime := time.Now().Format("2006-01-02")
+	now := cd.Now()
+	dateTime := cd.DayBoundary.Day(now)
 	radicalsKnown := 0
 	kanjiKnown := 0
 	vocabularyKnown := 0
@@ -251,37 +406,62 @@ func (cd *CardData) SaveHistoricalData() {
 		}
 	}
 
-	// Save historical data
-	csvLine := fmt.Sprintf("%s,%d,%d,%d,%d", dateTime, radicalsKnown, kanjiKnown, vocabularyKnown, grammarKnown)
-	historicalDataFile, err := os.OpenFile(filepath.Join(cd.DataDir, "historical-data.csv"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
+	// Days spent on vacation, even in part, are marked with a 1
+	onVacation := 0
+	for _, v := range cd.Vacations {
+		if v.Overlaps(cd.DayBoundary.DayStart(now), cd.DayBoundary.NextDayStart(now), now) {
+			onVacation = 1
+		}
 	}
-	defer historicalDataFile.Close()
 
-	log.Printf("Saving historical data: %s", csvLine)
-	_, err = historicalDataFile.WriteString(csvLine + "\n")
-	if err != nil {
-		log.Fatal(err)
+	// Save historical data, keeping one line per day.
+	// Today's line is replaced if it was already recorded, e.g. before a restart.
+	csvLine := fmt.Sprintf("%s,%d,%d,%d,%d,%d", dateTime, radicalsKnown, kanjiKnown, vocabularyKnown, grammarKnown, onVacation)
+	b, err := ioutil.ReadFile(cd.HistoricalDataFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" || strings.HasPrefix(line, dateTime+",") {
+			continue
+		}
+		lines = append(lines, line)
 	}
+	lines = append(lines, csvLine)
+
+	log.Printf("Saving historical data: %s", csvLine)
+	return writeFileAtomic(cd.HistoricalDataFile(), []byte(strings.Join(lines, "\n")+"\n"))
 }
 
-func DoHistoricalData(cd *CardData) {
+// Save historical data at the start of every study day until ctx is done
+func DoHistoricalData(ctx context.Context, cd *CardData) {
 	for {
-		// Wait until the next day at 00:00 and then save historical data
-		nextMidnight := time.Now().AddDate(0, 0, 1).Truncate(24 * time.Hour)
-		log.Printf("Waiting until %s to save historical data", nextMidnight.Format("2006-01-02 15:04:05"))
-		time.Sleep(time.Until(nextMidnight))
-		cd.SaveHistoricalData()
+		// Wait until the next study day starts and then save historical data
+		nextDay := cd.DayBoundary.NextDayStart(cd.Now())
+		log.Printf("Waiting until %s to save historical data", nextDay.Format("2006-01-02 15:04:05 MST"))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nextDay.Sub(cd.Now())):
+		}
+		err := cd.Snapshot().SaveHistoricalData()
+		if err != nil {
+			log.Printf("Error saving historical data: %s", err)
+		}
 	}
 }
 
-func (cd *CardData) AddUpNextCards(n int) {
-	// Add n cards to the up next list
+func (cd *CardData) AddUpNextCards(n int) error {
+	// Add n cards to the up next list, or fewer if that would go over the daily limit
+	n = cd.newCardsAllowed(n)
 
 	cs := cd.ToList()
-	cs = filterCardsByLearningStage(cs, UpNext)
-	cs = sortCardsByDue(cs)
+	cs = filterCardsByFacetInStage(cs, UpNext)
+	cs, err := sortCardsByDue(cs)
+	if err != nil {
+		return err
+	}
 	// Invert the card list to prioritise cards with alter due dates.
 	// This is because when cards are reviewed, the due date is pushed
 	// We want to review the cards we've seen more times, first.
@@ -294,6 +474,7 @@ func (cd *CardData) AddUpNextCards(n int) {
 			cs = cs[1:]
 		}
 	}
+	return nil
 }
 
 func (cd *CardData) RemoveUpNextCard(id int) {
@@ -349,8 +530,11 @@ func (cd *CardData) FindGrammar(grammar string) *Card {
 	return nil
 }
 
-func (cd *CardData) DeleteCard(id int) {
-	cd.BackupCardMap()
+func (cd *CardData) DeleteCard(id int) error {
+	err := cd.BackupCardMap()
+	if err != nil {
+		return err
+	}
 
 	delete(cd.Cards, id)
 
@@ -362,6 +546,7 @@ func (cd *CardData) DeleteCard(id int) {
 	}
 
 	log.Printf("Deleted card %d", id)
+	return nil
 }
 
 func filterCardsByLearned(cardData []*Card) []*Card {
@@ -378,6 +563,17 @@ func filterCardsByLearningStage(cardData []*Card, learningStage LearningStage) [
 	return cards
 }
 
+// Cards with any facet in the stage, e.g. a card whose meaning has been learned but whose reading is still up next
+func filterCardsByFacetInStage(cardData []*Card, learningStage LearningStage) []*Card {
+	var cards []*Card
+	for _, card := range cardData {
+		if card.HasFacetInStage(learningStage) {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
 func filterCardsByLevel(cardData []*Card, level int) []*Card {
 	var cards []*Card
 	for _, card := range cardData {
@@ -388,11 +584,15 @@ func filterCardsByLevel(cardData []*Card, level int) []*Card {
 	return cards
 }
 
-func filterCardsByDueBefore(cardData []*Card, endTime time.Time) []*Card {
+func filterCardsByDueBefore(cardData []*Card, endTime time.Time) ([]*Card, error) {
 	return filterCardsByDueBetween(cardData, time.Time{}, endTime)
 }
 
-func filterCardsByDueBetween(cardData []*Card, startTime time.Time, endTime time.Time) []*Card {
+func invalidReviewDate(c *Card, err error) error {
+	return fmt.Errorf("card %d has an invalid next review date %q: %w", c.ID, c.NextReviewDate, err)
+}
+
+func filterCardsByDueBetween(cardData []*Card, startTime time.Time, endTime time.Time) ([]*Card, error) {
 	var cards []*Card
 	for _, card := range cardData {
 		// Convert ISO 8601 string to time.Time
@@ -404,7 +604,7 @@ func filterCardsByDueBetween(cardData []*Card, startTime time.Time, endTime time
 		}
 		t, err := time.Parse(time.RFC3339, ct)
 		if err != nil {
-			panic(err)
+			return nil, invalidReviewDate(card, err)
 		}
 
 		// If the card is due between the start and end times, add it to the list
@@ -413,7 +613,7 @@ func filterCardsByDueBetween(cardData []*Card, startTime time.Time, endTime time
 			cards = append(cards, card)
 		}
 	}
-	return cards
+	return cards, nil
 }
 
 // Currently unused
@@ -526,7 +726,8 @@ func sortCardsById(cards []*Card) []*Card {
 	return cards
 }
 
-func sortCardsByDue(cards []*Card) []*Card {
+func sortCardsByDue(cards []*Card) ([]*Card, error) {
+	var sortErr error
 	sort.Slice(cards, func(i, j int) bool {
 		// Convert ISO 8601 string to time.Time
 		ct := cards[i].NextReviewDate
@@ -536,7 +737,8 @@ func sortCardsByDue(cards []*Card) []*Card {
 		}
 		t, err := time.Parse(time.RFC3339, ct)
 		if err != nil {
-			panic(err)
+			sortErr = invalidReviewDate(cards[i], err)
+			return false
 		}
 
 		// Do the same for the second card
@@ -548,12 +750,16 @@ func sortCardsByDue(cards []*Card) []*Card {
 		}
 		t2, err := time.Parse(time.RFC3339, ct2)
 		if err != nil {
-			panic(err)
+			sortErr = invalidReviewDate(cards[j], err)
+			return false
 		}
 
 		return t.Before(t2)
 	})
-	return cards
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return cards, nil
 }
 
 func sortCardsByReviewPerformance(cards []*Card) []*Card {
@@ -723,20 +929,27 @@ func removeCard(s []*Card, e *Card) []*Card {
 	return s
 }
 
-func (cd *CardData) GetScheduleData() []ScheduleEntry {
+func (cd *CardData) GetScheduleData() ([]ScheduleEntry, error) {
 	// Find the counts of reviews for each hour for the next 48 hours
 	var scheduleData []ScheduleEntry
 	var t1, t2 time.Time
-	cards := cd.ToList()
+	cards := expandFacets(cd.ToList())
+	// While on vacation, show the schedule as it will be if the vacation ends now
+	if v := cd.CurrentVacation(); v != nil {
+		cards = shiftedForVacation(cards, v, cd.Now())
+	}
 
 	// Initialise t1 to the next XX:00
 	// And set t2 to the next hour
-	t1 = time.Now().Truncate(time.Hour)
+	t1 = cd.Now().Truncate(time.Hour)
 	t2 = t1.Add(time.Hour)
 
 	for i := 0; i < 300; i++ {
 		// Get the number of cards that will be reviewed in this hour period
-		cs := filterCardsByDueBetween(cards, t1, t2)
+		cs, err := filterCardsByDueBetween(cards, t1, t2)
+		if err != nil {
+			return nil, err
+		}
 		count := len(cs)
 		scheduleData = append(scheduleData, ScheduleEntry{
 			Time:  t2.Format("2006-01-02 15:04"),
@@ -748,7 +961,7 @@ func (cd *CardData) GetScheduleData() []ScheduleEntry {
 		t2 = t2.Add(time.Hour)
 	}
 
-	return scheduleData
+	return scheduleData, nil
 }
 
 type KanjiFrequency struct {
@@ -757,13 +970,16 @@ type KanjiFrequency struct {
 	Data  []interface{} `json:"data"` // <KANJI>,<COUNT>,<PERCENTAGE>
 }
 
-func (cd *CardData) GetKanjiFrequencyData() []KanjiFrequencyData {
+func (cd *CardData) GetKanjiFrequencyData() ([]KanjiFrequencyData, error) {
 	// For each file in the kanji frequency data directory,
 	// Calculate the known percentage of the kanji.
 
-	files, err := ioutil.ReadDir("data/kanji_frequencies")
+	files, err := ioutil.ReadDir(cd.GetKanjiFrequencyDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	// Create a list of known kanji
@@ -777,27 +993,31 @@ func (cd *CardData) GetKanjiFrequencyData() []KanjiFrequencyData {
 	var kanjiFrequencyData []KanjiFrequencyData
 
 	for _, f := range files {
-		filepath := filepath.Join("data/kanji_frequencies", f.Name())
-		file, err := os.Open(filepath)
+		// Read and unmarshal the file
+		b, err := ioutil.ReadFile(filepath.Join(cd.GetKanjiFrequencyDir(), f.Name()))
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		defer file.Close()
-
-		// Read and unmarshal the file
 		var data KanjiFrequency
-		decoder := json.NewDecoder(file)
-		err = decoder.Decode(&data)
+		err = json.Unmarshal(b, &data)
 		if err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("%s: %w", f.Name(), err)
 		}
 
 		// Calculate the percentage of known kanji
 		knownPercentage := 0.0
 		for _, d := range data.Data {
-			kanji := d.([]interface{})[0].(string)
+			row, ok := d.([]interface{})
+			if !ok || len(row) < 3 {
+				return nil, fmt.Errorf("%s: expected rows of <KANJI>,<COUNT>,<PERCENTAGE>, got %v", f.Name(), d)
+			}
+			kanji, ok1 := row[0].(string)
+			percentage, ok2 := row[2].(float64)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("%s: expected rows of <KANJI>,<COUNT>,<PERCENTAGE>, got %v", f.Name(), d)
+			}
 			if containsString(knownKanji, kanji) {
-				knownPercentage += d.([]interface{})[2].(float64)
+				knownPercentage += percentage
 			}
 		}
 
@@ -808,5 +1028,35 @@ func (cd *CardData) GetKanjiFrequencyData() []KanjiFrequencyData {
 		})
 	}
 
-	return kanjiFrequencyData
+	return kanjiFrequencyData, nil
+}
+
+// Directory that holds this profile's progress, review log, historical data and text analyses
+func (cd *CardData) GetProfileDir() string {
+	if cd.ProfileDir == "" {
+		return cd.DataDir
+	}
+	return cd.ProfileDir
+}
+
+func (cd *CardData) HistoricalDataFile() string {
+	return filepath.Join(cd.GetProfileDir(), "historical-data.csv")
+}
+
+func (cd *CardData) TextAnalysisDir() string {
+	return filepath.Join(cd.GetProfileDir(), "text_analysis")
+}
+
+func (cd *CardData) GetDictionaryFile() string {
+	if cd.DictionaryFile == "" {
+		return filepath.Join(cd.DataDir, "JMdict_e")
+	}
+	return cd.DictionaryFile
+}
+
+func (cd *CardData) GetKanjiFrequencyDir() string {
+	if cd.KanjiFrequencyDir == "" {
+		return filepath.Join(cd.DataDir, "kanji_frequencies")
+	}
+	return cd.KanjiFrequencyDir
 }
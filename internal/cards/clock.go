@@ -0,0 +1,57 @@
+package cards
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Scheduling asks the card data's clock rather than calling time.Now,
+// so the simulation, tests and "what is due at time T" can run at a time of their choosing.
+// Things that really happen at the time they happen, such as sessions, backups and the request log, use the wall clock.
+
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock only moves when told to
+type FixedClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewFixedClock(t time.Time) *FixedClock {
+	return &FixedClock{t: t}
+}
+
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *FixedClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// The time according to the card data's clock, or the wall clock if it has none
+func (cd *CardData) Now() time.Time {
+	if cd.Clock == nil {
+		return time.Now()
+	}
+	return cd.Clock.Now()
+}
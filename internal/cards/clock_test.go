@@ -0,0 +1,59 @@
+package cards
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixedClockAnswers(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+	cd.Scheduler = DoublingScheduler{SrsSettings: SrsSettings{NoFuzz: true}}
+	cd.Cards[1].NextReviewDate = "2030-01-01T00:00:00Z"
+	cd.Cards[2].NextReviewDate = "2030-01-03T00:00:00Z"
+	clock := NewFixedClock(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC))
+	cd.Clock = clock
+
+	// Only card 1 is due at the clock's time, however far away that is from the wall clock
+	srsData := cd.Snapshot().GetNextSrsCard()
+	if srsData.DueCount != 1 || srsData.Card.ID != 1 {
+		t.Errorf("Expected only card 1 to be due, got %d due", srsData.DueCount)
+	}
+	if result, _ := cd.AnswerCard(2, MeaningFacet, Good, 0); result.Answered {
+		t.Errorf("Expected card 2 not to be due yet")
+	}
+
+	cd.AnswerCard(1, MeaningFacet, Good, 0)
+	if d := cd.Cards[1].NextReviewDate; d != "2030-01-06T00:00:00Z" {
+		t.Errorf("Expected the next review 96 hours after the clock, got %s", d)
+	}
+	entries, err := cd.GetCardReviewLog(1)
+	if err != nil || len(entries) != 1 || entries[0].Timestamp != "2030-01-02T00:00:00Z" {
+		t.Errorf("Expected the review to be logged at the clock's time, got %v %v", entries, err)
+	}
+
+	clock.Advance(24 * time.Hour)
+	if result, _ := cd.AnswerCard(2, MeaningFacet, Good, 0); !result.Answered {
+		t.Errorf("Expected card 2 to be due once the clock has moved on")
+	}
+}
+
+func TestSimulationUsesClock(t *testing.T) {
+	cd, h := createTestRouter(t, 1)
+	cd.Cards[1].Meanings = []Meaning{{Meaning: "one", Primary: true, AcceptedAnswer: true}}
+	cd.Clock = NewFixedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/cardoverview/simulate/1/1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Day 59") {
+		t.Errorf("Expected 60 days to be simulated")
+	}
+	if cd.Cards[1].NextReviewDate != "2020-01-01T00:00:00Z" {
+		t.Errorf("Expected the simulation not to change the real cards, got %s", cd.Cards[1].NextReviewDate)
+	}
+}
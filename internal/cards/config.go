@@ -0,0 +1,113 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Every command line flag can also be set in a JSON config file, using the flag's name as the key,
+// or with an environment variable named MOEKYU_ followed by the flag's name in upper case, with - replaced by _.
+// Flags given on the command line win over environment variables, which win over the config file.
+//
+//	{"listen": ":9000", "data-dir": "/srv/moe", "srs-burn-interval": 4380}
+//
+// is the same as MOEKYU_LISTEN=:9000 MOEKYU_DATA_DIR=/srv/moe MOEKYU_SRS_BURN_INTERVAL=4380
+
+const envPrefix = "MOEKYU_"
+
+// The environment variable that sets a flag
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Set the flags of fs that weren't given on the command line from environ (as returned by os.Environ),
+// then from the config file named by the configFlag flag, if any. Call after fs has been parsed.
+func LoadConfig(fs *flag.FlagSet, configFlag string, environ []string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	env := map[string]string{}
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i > 0 && strings.HasPrefix(kv, envPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := env[EnvName(f.Name)]
+		if err != nil || set[f.Name] || !ok {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("%s: invalid value %q: %w", EnvName(f.Name), v, e)
+		}
+		set[f.Name] = true
+	})
+	if err != nil {
+		return err
+	}
+
+	cf := fs.Lookup(configFlag)
+	if cf == nil || cf.Value.String() == "" {
+		return nil
+	}
+	return loadConfigFile(fs, cf.Value.String(), set)
+}
+
+// Set the flags not in set from a JSON config file
+func loadConfigFile(fs *flag.FlagSet, path string, set map[string]bool) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err = d.Decode(&config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Sorted so that the first bad setting is always the one reported
+	var names []string
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		if set[name] {
+			continue
+		}
+
+		var v string
+		switch value := config[name].(type) {
+		case string:
+			v = value
+		case json.Number:
+			v = value.String()
+		case bool:
+			v = fmt.Sprint(value)
+		default:
+			return fmt.Errorf("%s: %s must be a string, number or boolean", path, name)
+		}
+		err = fs.Set(name, v)
+		if err != nil {
+			return fmt.Errorf("%s: %s: invalid value %q: %w", path, name, v, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package cards
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	err := ioutil.WriteFile(config, []byte(`{"listen": ":9000", "data-dir": "/srv/data", "static-dir": "/srv/static", "srs-burn-interval": 4380, "read-timeout": "10s"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("config", "", "")
+	listen := fs.String("listen", ":8080", "")
+	dataDir := fs.String("data-dir", "data", "")
+	staticDir := fs.String("static-dir", "static", "")
+	cardsFile := fs.String("cards-file", "data/cards.json", "")
+	burn := fs.Int("srs-burn-interval", 8760, "")
+	readTimeout := fs.Duration("read-timeout", 30*time.Second, "")
+	err = fs.Parse([]string{"-listen", ":7000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	environ := []string{"MOEKYU_CONFIG=" + config, "MOEKYU_DATA_DIR=/env/data", "HOME=/root"}
+	err = LoadConfig(fs, "config", environ)
+	if err != nil {
+		t.Fatalf("Error loading config: %s", err)
+	}
+	if *listen != ":7000" {
+		t.Errorf("Expected the command line to win, got %s", *listen)
+	}
+	if *dataDir != "/env/data" {
+		t.Errorf("Expected the environment to win over the config file, got %s", *dataDir)
+	}
+	if *staticDir != "/srv/static" || *burn != 4380 || *readTimeout != 10*time.Second {
+		t.Errorf("Expected settings from the config file, got %s %d %s", *staticDir, *burn, *readTimeout)
+	}
+	if *cardsFile != "data/cards.json" {
+		t.Errorf("Expected the default cards file, got %s", *cardsFile)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, contents := range []string{`{"nonsense": 1}`, `{"srs-burn-interval": "forever"}`, `{"listen": [":8080"]}`, `{"listen": `} {
+		config := filepath.Join(dir, "config.json")
+		err := ioutil.WriteFile(config, []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("config", config, "")
+		fs.String("listen", ":8080", "")
+		fs.Int("srs-burn-interval", 8760, "")
+		err = LoadConfig(fs, "config", nil)
+		if err == nil {
+			t.Errorf("Expected an error for %s", contents)
+		}
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("srs-burn-interval", 8760, "")
+	err := LoadConfig(fs, "config", []string{"MOEKYU_SRS_BURN_INTERVAL=forever"})
+	if err == nil {
+		t.Errorf("Expected an error for a bad environment variable")
+	}
+}
@@ -0,0 +1,88 @@
+package cards
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Every request that changes something must be a POST carrying a CSRF token.
+// The token is kept in an HttpOnly cookie and written into each page by the csrftoken template function.
+// Pages send it back in the csrf_token form field or the X-CSRF-Token header. Another site can make
+// the browser POST to us, but it can't read the cookie or our pages, so it can't send the matching token.
+//
+// Requests with an "Authorization: Bearer" header don't need a token. Browsers never add one
+// to a cross site request on their own, so such a request can't have been forged.
+// Basic authentication doesn't count, as browsers resend it automatically, e.g. to a reverse proxy.
+// The API login is exempt too. It only returns a token and sets no cookie, so forging it gains nothing.
+
+const csrfCookie = "csrf"
+const csrfField = "csrf_token"
+const csrfHeader = "X-CSRF-Token"
+
+type csrfContextKey struct{}
+
+func CSRFProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+			token = cookie.Value
+		}
+
+		if !isSafeMethod(r.Method) && !hasBearerToken(r) && r.URL.Path != apiPrefix+"/login" {
+			sent := r.Header.Get(csrfHeader)
+			if sent == "" {
+				sent = r.PostFormValue(csrfField)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				log.Printf("Rejecting %s %s with a missing or wrong CSRF token", r.Method, r.URL.Path)
+				http.Error(w, "Missing or invalid CSRF token. Reload the page and try again.", http.StatusForbidden)
+				return
+			}
+		}
+
+		if token == "" {
+			b := make([]byte, 32)
+			_, err := rand.Read(b)
+			if err != nil {
+				log.Printf("Error making a CSRF token: %s", err)
+				http.Error(w, errInternal.Error(), http.StatusInternalServerError)
+				return
+			}
+			token = base64.RawURLEncoding.EncodeToString(b)
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   isHTTPS(r),
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token)))
+	})
+}
+
+// The CSRF token for pages to send back with their POSTs
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey{}).(string)
+	return token
+}
+
+func hasBearerToken(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// Whether the browser is talking to us over HTTPS, possibly through a reverse proxy
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,185 @@
+package cards
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// A study day starts at DayBoundary.Start in its timezone, rather than at midnight UTC,
+// so that a late night session counts towards the day it started on.
+// The daily limits on new cards and reviews, and the historical data, go by study days.
+
+type DayBoundary struct {
+	Start    time.Duration  // Time of day the day starts, e.g. 4 hours for 04:00
+	Location *time.Location // Local time if nil
+}
+
+// Parse a day start such as "04:00", and a timezone such as "Europe/London". An empty timezone is local time.
+func ParseDayBoundary(start string, timezone string) (DayBoundary, error) {
+	t, err := time.Parse("15:04", start)
+	if err != nil {
+		return DayBoundary{}, fmt.Errorf("invalid day start %q, expected HH:MM", start)
+	}
+	b := DayBoundary{Start: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}
+	if timezone != "" {
+		b.Location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return DayBoundary{}, err
+		}
+	}
+	return b, nil
+}
+
+func (b DayBoundary) location() *time.Location {
+	if b.Location == nil {
+		return time.Local
+	}
+	return b.Location
+}
+
+// The start of the study day that t is in
+func (b DayBoundary) DayStart(t time.Time) time.Time {
+	t = t.In(b.location())
+	h, m := int(b.Start.Hours()), int(b.Start.Minutes())%60
+	start := time.Date(t.Year(), t.Month(), t.Day(), h, m, 0, 0, t.Location())
+	if t.Before(start) {
+		start = time.Date(t.Year(), t.Month(), t.Day()-1, h, m, 0, 0, t.Location())
+	}
+	return start
+}
+
+// The start of the study day after the one that t is in
+func (b DayBoundary) NextDayStart(t time.Time) time.Time {
+	start := b.DayStart(t)
+	h, m := int(b.Start.Hours()), int(b.Start.Minutes())%60
+	return time.Date(start.Year(), start.Month(), start.Day()+1, h, m, 0, 0, start.Location())
+}
+
+// The date of the study day that t is in, as 2006-01-02
+func (b DayBoundary) Day(t time.Time) string {
+	return b.DayStart(t).Format("2006-01-02")
+}
+
+// What has been studied so far today, from the review log
+type DayCounts struct {
+	NewCards map[int]bool // Cards answered for the first time. Cards with a reading can take a few answers to leave up next.
+	Reviews  int          // Answers to learned facets
+}
+
+func (cd *CardData) StudiedToday() (DayCounts, error) {
+	counts := DayCounts{NewCards: make(map[int]bool)}
+	answers, err := cd.todayLog().answers(cd)
+	if err != nil {
+		return counts, err
+	}
+	for _, e := range answers {
+		switch e.PreviousStage {
+		case UpNext:
+			counts.NewCards[e.CardID] = true
+		case Learned:
+			counts.Reviews++
+		}
+	}
+	return counts, nil
+}
+
+// Today's answers, kept so the daily limits don't read the whole review log on every request.
+// The log is read when a study day starts, then each answer and undo is applied as it is logged.
+// Shared between a card data and its snapshots.
+type dayLog struct {
+	mu   sync.Mutex
+	day  string           // Study day the answers are for. Empty until the log has been read.
+	done []ReviewLogEntry // Today's answers, less any that were undone
+}
+
+func (cd *CardData) todayLog() *dayLog {
+	cd.todayOnce.Do(func() {
+		if cd.today == nil {
+			cd.today = &dayLog{}
+		}
+	})
+	return cd.today
+}
+
+// Today's answers, reading them from the review log if the day has changed since they were last read
+func (d *dayLog) answers(cd *CardData) ([]ReviewLogEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := cd.Now()
+	if day := cd.DayBoundary.Day(now); day != d.day {
+		entries, err := cd.GetReviewLog()
+		if err != nil {
+			return nil, err
+		}
+		start, end := cd.DayBoundary.DayStart(now), cd.DayBoundary.NextDayStart(now)
+		d.done = nil
+		for _, e := range entries {
+			t, err := time.Parse(time.RFC3339, e.Timestamp)
+			if err == nil && !t.Before(start) && t.Before(end) {
+				d.done = append(d.done, e)
+			}
+		}
+		d.day = day
+	}
+	return append([]ReviewLogEntry(nil), d.done...), nil
+}
+
+// Apply an entry that has just been logged. An undo drops the answer it undoes.
+// Caller must hold d.mu, so the entry isn't also picked up by a concurrent read of the log.
+func (d *dayLog) add(b DayBoundary, entry ReviewLogEntry) {
+	t, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil || d.day == "" || b.Day(t) != d.day {
+		return
+	}
+	if entry.Undo {
+		d.done = removeUndoneAnswer(d.done, entry)
+		return
+	}
+	d.done = append(d.done, entry)
+}
+
+// How many of n new cards can be added to the up next queue without going over the daily limit.
+// Cards already on the queue count as today's, as they are about to be learned.
+// Caller must hold the lock
+func (cd *CardData) newCardsAllowed(n int) int {
+	limit := cd.GetScheduler().Settings().NewCardsPerDay
+	if limit <= 0 {
+		return n
+	}
+	counts, err := cd.StudiedToday()
+	if err != nil {
+		log.Printf("Error reading review log: %s", err)
+		return n
+	}
+	for _, c := range cd.UpNext {
+		counts.NewCards[c.ID] = true
+	}
+	left := limit - len(counts.NewCards)
+	if left < 0 {
+		left = 0
+	}
+	if n > left {
+		log.Printf("%d new cards have been started today, so only %d more can be added", len(counts.NewCards), left)
+		return left
+	}
+	return n
+}
+
+// How many learned reviews are left today, or -1 if there is no limit
+func (cd *CardData) reviewsLeft() int {
+	limit := cd.GetScheduler().Settings().ReviewsPerDay
+	if limit <= 0 {
+		return -1
+	}
+	counts, err := cd.StudiedToday()
+	if err != nil {
+		log.Printf("Error reading review log: %s", err)
+		return -1
+	}
+	if counts.Reviews >= limit {
+		return 0
+	}
+	return limit - counts.Reviews
+}
@@ -0,0 +1,181 @@
+package cards
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDayBoundary(t *testing.T) {
+	b, err := ParseDayBoundary("04:00", "UTC")
+	if err != nil {
+		t.Fatalf("Error parsing day boundary: %s", err)
+	}
+
+	// Before 04:00 is still the day before
+	early := time.Date(2023, 8, 5, 3, 0, 0, 0, time.UTC)
+	if d := b.Day(early); d != "2023-08-04" {
+		t.Errorf("Expected 03:00 to be on 2023-08-04, got %s", d)
+	}
+	if s := b.DayStart(early); !s.Equal(time.Date(2023, 8, 4, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the day to start at 2023-08-04 04:00, got %s", s)
+	}
+	if s := b.NextDayStart(early); !s.Equal(time.Date(2023, 8, 5, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the next day to start at 2023-08-05 04:00, got %s", s)
+	}
+	if d := b.Day(early.Add(time.Hour)); d != "2023-08-05" {
+		t.Errorf("Expected 04:00 to be on 2023-08-05, got %s", d)
+	}
+
+	// The day starts in the given timezone. 20:00 UTC is 05:00 the next day in Tokyo.
+	b, err = ParseDayBoundary("04:00", "Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Error parsing day boundary: %s", err)
+	}
+	if d := b.Day(time.Date(2023, 8, 4, 20, 0, 0, 0, time.UTC)); d != "2023-08-05" {
+		t.Errorf("Expected the day to be 2023-08-05 in Tokyo, got %s", d)
+	}
+
+	if _, err = ParseDayBoundary("25:00", ""); err == nil {
+		t.Errorf("Expected an error for an invalid day start")
+	}
+	if _, err = ParseDayBoundary("04:00", "Nowhere/Nowhere"); err == nil {
+		t.Errorf("Expected an error for an unknown timezone")
+	}
+}
+
+func logAnswer(t *testing.T, cd *CardData, id int, stage LearningStage, at time.Time) {
+	err := cd.appendReviewLog(ReviewLogEntry{
+		CardID:        id,
+		Facet:         MeaningFacet,
+		Timestamp:     at.Format(time.RFC3339),
+		Grade:         Good,
+		PreviousStage: stage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDailyNewCardLimit(t *testing.T) {
+	var cs []*Card
+	for i := 1; i <= 5; i++ {
+		c := CreateCard(i, 0, 0, "1970-01-01T00:00:00Z")
+		c.QueuedToLearn = true
+		cs = append(cs, c)
+	}
+	cd := CreateCardDataFromSlice(cs)
+	cd.DataDir = t.TempDir()
+	cd.CardsFile = filepath.Join(cd.DataDir, "cards.json")
+	cd.Scheduler = DoublingScheduler{SrsSettings: SrsSettings{NewCardsPerDay: 3}}
+	cd.UpdateCardData()
+
+	// One new card today, and one from two days ago that doesn't count
+	logAnswer(t, cd, 6, UpNext, time.Now())
+	logAnswer(t, cd, 6, UpNext, time.Now())
+	logAnswer(t, cd, 7, UpNext, time.Now().Add(-48*time.Hour))
+
+	cd.QueueUpNextCards(5)
+	if len(cd.UpNext) != 2 {
+		t.Errorf("Expected 2 cards to be added, got %d", len(cd.UpNext))
+	}
+	cd.QueueUpNextCards(5)
+	if len(cd.UpNext) != 2 {
+		t.Errorf("Expected no more cards to be added, got %d", len(cd.UpNext))
+	}
+}
+
+func TestDailyReviewLimit(t *testing.T) {
+	cd := createStoreCardData(t, 4)
+	cd.Scheduler = DoublingScheduler{SrsSettings: SrsSettings{ReviewsPerDay: 3}}
+	logAnswer(t, cd, 5, Learned, time.Now())
+	logAnswer(t, cd, 5, Learned, time.Now().Add(-48*time.Hour))
+
+	srsData := cd.GetNextSrsCard()
+	if srsData.DueCount != 2 || srsData.HeldBack != 2 {
+		t.Errorf("Expected 2 reviews and 2 held back, got %d and %d", srsData.DueCount, srsData.HeldBack)
+	}
+
+	// Learning reviews aren't limited
+	cd.Cards[1].LearningStage = Learning
+	cd.Cards[1].Interval = 0
+	cd.Cards[1].LearningInterval = 4
+	cd.UpdateCardData()
+	logAnswer(t, cd, 5, Learned, time.Now())
+	logAnswer(t, cd, 5, Learned, time.Now())
+	srsData = cd.GetNextSrsCard()
+	if srsData.DueCount != 1 || srsData.LearningCount != 1 || srsData.HeldBack != 3 {
+		t.Errorf("Expected only the learning review, got %d due and %d held back", srsData.DueCount, srsData.HeldBack)
+	}
+}
+
+func TestApiSrsNextHeldBack(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+	cd.Scheduler = DoublingScheduler{SrsSettings: SrsSettings{ReviewsPerDay: 1}}
+	logAnswer(t, cd, 3, Learned, time.Now())
+
+	w := apiRequest(h, "GET", "/api/v1/srs/next", "")
+	var next ApiSrsNext
+	json.Unmarshal(w.Body.Bytes(), &next)
+	if next.Card != nil || next.HeldBack != 2 {
+		t.Errorf("Expected both reviews to be held back, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/srs", nil))
+	if !strings.Contains(w.Body.String(), "today's review limit") {
+		t.Errorf("Expected the SRS page to say the limit was reached")
+	}
+}
+
+func TestUndoneAnswersDontCount(t *testing.T) {
+	// A new card
+	cd := createUndoCardData(t)
+	cd.AnswerCardInSession("a", 1, MeaningFacet, Good, 0)
+	if counts, _ := cd.StudiedToday(); len(counts.NewCards) != 1 {
+		t.Errorf("Expected 1 new card, got %d", len(counts.NewCards))
+	}
+	cd.UndoAnswer("a")
+	if counts, _ := cd.StudiedToday(); len(counts.NewCards) != 0 {
+		t.Errorf("Expected the undone card not to count, got %d", len(counts.NewCards))
+	}
+
+	// A review, with the log read before and after the answer
+	cd = createStoreCardData(t, 1)
+	cd.StudiedToday()
+	cd.AnswerCardInSession("a", 1, MeaningFacet, Good, 0)
+	cd.UndoAnswer("a")
+	if counts, _ := cd.StudiedToday(); counts.Reviews != 0 {
+		t.Errorf("Expected the undone review not to count, got %d", counts.Reviews)
+	}
+	cd.todayLog().day = ""
+	if counts, _ := cd.StudiedToday(); counts.Reviews != 0 {
+		t.Errorf("Expected the undone review not to count when the log is read again, got %d", counts.Reviews)
+	}
+}
+
+func TestStudiedTodayReadsLogOncePerDay(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+	clock := NewFixedClock(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC))
+	cd.Clock = clock
+	cd.DayBoundary = DayBoundary{Start: 4 * time.Hour, Location: time.UTC}
+	logAnswer(t, cd, 3, Learned, clock.Now())
+	cd.StudiedToday()
+
+	// Answers are counted as they are logged, without reading the log again
+	logAnswer(t, cd, 3, Learned, clock.Now())
+	os.Remove(cd.ReviewLogFile())
+	if counts, _ := cd.StudiedToday(); counts.Reviews != 2 {
+		t.Errorf("Expected 2 reviews today, got %d", counts.Reviews)
+	}
+
+	// The next day starts afresh
+	clock.Advance(24 * time.Hour)
+	if counts, _ := cd.StudiedToday(); counts.Reviews != 0 {
+		t.Errorf("Expected no reviews on the next day, got %d", counts.Reviews)
+	}
+}
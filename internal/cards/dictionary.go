@@ -1,6 +1,8 @@
 package cards
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 
@@ -27,7 +29,7 @@ type DictionaryDefinition struct {
 func (cd *CardData) LoadDictionary() {
 	log.Printf("Loading dictionary...")
 
-	f, err := os.Open(cd.DataDir + "/JMdict_e")
+	f, err := os.Open(cd.GetDictionaryFile())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -133,7 +135,7 @@ func (t *Token) AddDictionaryEntry(cd *CardData) {
 	}
 }
 
-func SearchDictionary(cd *CardData, query string) DictionarySearchData {
+func SearchDictionary(cd *CardData, query string) (DictionarySearchData, error) {
 	// If query is in romanji, convert it to hiragana
 	originalQuery := query
 	if !kana.ContainsHiragana(query) && !kana.ContainsKatakana(query) && !kana.ContainsKanji(query) {
@@ -142,7 +144,7 @@ func SearchDictionary(cd *CardData, query string) DictionarySearchData {
 
 	t, err := tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
 	if err != nil {
-		panic(err)
+		return DictionarySearchData{}, err
 	}
 
 	tokens := t.Analyze(query, tokenizer.Normal)
@@ -183,7 +185,7 @@ func SearchDictionary(cd *CardData, query string) DictionarySearchData {
 		DictSearchTerm:    originalQuery,
 		DictSearchResults: dedupedResult,
 		Tokens:            tokenStrings,
-	}
+	}, nil
 }
 
 func GetDictionaryEntries(term string, cd *CardData, dict map[string][]*jmdict.JmdictEntry) []DictionaryEntry {
@@ -249,3 +251,83 @@ func ConvertJmDictPOSt(pos string) string {
 		return pos
 	}
 }
+
+// Use a dictionary already loaded by other card data, rather than loading it again.
+// The dictionary is never modified after loading, so it is safe to share.
+func (cd *CardData) ShareDictionary(from *CardData) {
+	cd.Dictionary = from.Dictionary
+	cd.DictionaryMap = from.DictionaryMap
+	cd.DictionaryKanjiMap = from.DictionaryKanjiMap
+	cd.DictionaryReadingMap = from.DictionaryReadingMap
+	cd.DictionaryNonKanjiReadingMap = from.DictionaryNonKanjiReadingMap
+	cd.DictionaryMeaningMap = from.DictionaryMeaningMap
+	cd.DictionaryEntities = from.DictionaryEntities
+}
+
+var ErrDictionaryEntryNotFound = errors.New("dictionary entry not found")
+
+// A new vocabulary card for a dictionary entry, with the kanji cards it is made of as components.
+// The card is not added to the card data.
+func (cd *CardData) NewCardFromDictionary(id int) (*Card, error) {
+	entry, ok := cd.DictionaryMap[id]
+	if !ok {
+		return nil, fmt.Errorf("dictionary entry %d: %w", id, ErrDictionaryEntryNotFound)
+	}
+	dictEntry := convertJmdictEntryToDictionaryEntry(cd, *entry)
+	mainCharacter := dictEntry.Expressions[0]
+	otherCharacters := dictEntry.Expressions[1:]
+
+	var meanings []Meaning
+	var partsOfSpeech []string
+	for _, m := range dictEntry.Definitions {
+		for _, d := range m.Definitions {
+			meanings = append(meanings, Meaning{
+				Meaning:        d,
+				Primary:        false,
+				AcceptedAnswer: false,
+			})
+		}
+		for _, m := range m.PartsOfSpeech {
+			if !containsString(partsOfSpeech, m) {
+				partsOfSpeech = append(partsOfSpeech, m)
+			}
+		}
+	}
+
+	var readings []Reading
+	for _, m := range dictEntry.Readings {
+		readings = append(readings, Reading{
+			Reading:        m,
+			Primary:        false,
+			AcceptedAnswer: false,
+		})
+	}
+
+	kanjis := kana.ExtractKanji(mainCharacter)
+	var componentIds []int
+	var defaultMeaningMneumonic string
+	for _, k := range kanjis {
+		kanjiCard := cd.FindKanji(k)
+		if kanjiCard != nil {
+			componentIds = append(componentIds, kanjiCard.ID)
+			defaultMeaningMneumonic += "<kanji>" + kanjiCard.Meanings[0].Meaning + "</kanji> "
+		}
+	}
+
+	// Create a new card
+	c := Card{
+		Object:                      "vocabulary", // New cards from dictionary are always vocabulary
+		Level:                       0,            // So they don't appear as a wanikani level card
+		Characters:                  mainCharacter,
+		CharactersAlternateWritings: otherCharacters,
+		Meanings:                    meanings,
+		Readings:                    readings,
+		PartsOfSpeech:               partsOfSpeech,
+		ComponentSubjectIDs:         componentIds,
+		Tags:                        []string{"TODO", "added_from_dictionary"},
+		MeaningMnemonic:             defaultMeaningMneumonic,
+		ReadingMnemonic:             "This is a jukugo word, which usually means on'yomi readings from the kanji. If you know the readings of your kanji you'll know how to read this as well.",
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,88 @@
+package cards
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Handlers return their errors instead of writing them. serveError then responds with
+// the status code that matches the error: an error page for the web pages, or {"error": "..."} for the API.
+// Only client errors are shown as they are. Server errors are logged, and the client gets a generic message.
+
+// An error to respond to with a particular status code
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func httpError(status int, err error) error {
+	return &HTTPError{Status: status, Err: err}
+}
+
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// The status code to respond to an error with
+func statusFor(err error) int {
+	var he *HTTPError
+	switch {
+	case errors.As(err, &he):
+		return he.Status
+	case errors.Is(err, ErrNotLoggedIn):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrCardNotFound), errors.Is(err, ErrTextAnalysisNotFound),
+		errors.Is(err, ErrDictionaryEntryNotFound), errors.Is(err, ErrBackupNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotQueueable), errors.Is(err, ErrNotReviewable), errors.Is(err, ErrNothingToUndo), errors.Is(err, ErrUndoConflict),
+		errors.Is(err, ErrOnVacation), errors.Is(err, ErrNotOnVacation):
+		return http.StatusConflict
+	case errors.Is(err, ErrNoSuchFacet):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+type ErrorData struct {
+	Status     int
+	StatusText string
+	Message    string
+}
+
+func (cd *CardData) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	if !errors.Is(err, ErrNotLoggedIn) {
+		log.Printf("Error handling %s %s: %s", r.Method, r.URL.Path, err)
+	}
+	status := statusFor(err)
+	if status >= 500 {
+		// The error can hold file paths and other internals, so it only goes in the log
+		err = errInternal
+	}
+	if isAPIRequest(r) {
+		writeJSONError(w, status, err)
+		return
+	}
+	if errors.Is(err, ErrNotLoggedIn) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	data := ErrorData{Status: status, StatusText: http.StatusText(status), Message: err.Error()}
+	err = cd.renderTemplate(w, r, status, "error.html", data)
+	if err != nil {
+		log.Printf("Error rendering the error page: %s", err)
+		http.Error(w, data.Message, status)
+	}
+}
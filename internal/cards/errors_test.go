@@ -0,0 +1,98 @@
+package cards
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusFor(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{httpError(http.StatusBadRequest, errors.New("bad")), http.StatusBadRequest},
+		{fmt.Errorf("card 1: %w", ErrCardNotFound), http.StatusNotFound},
+		{fmt.Errorf("backup x: %w", ErrBackupNotFound), http.StatusNotFound},
+		{fmt.Errorf("card 1: %w", ErrNotQueueable), http.StatusConflict},
+		{ErrNotLoggedIn, http.StatusUnauthorized},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		if status := statusFor(test.err); status != test.status {
+			t.Errorf("Expected status %d for %q, got %d", test.status, test.err, status)
+		}
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	_, h := createTestRouter(t, 2)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/card/99", http.StatusNotFound},
+		{"/card/99/edit", http.StatusNotFound},
+		{"/card/abc/json", http.StatusBadRequest},
+		{"/textanalysis/nothing", http.StatusNotFound},
+		{"/backups/nothing", http.StatusNotFound},
+		{"/cardoverview/simulate/abc/10", http.StatusBadRequest},
+		{"/nothing", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("Expected status %d for %s, got %d", test.status, test.path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), http.StatusText(test.status)) {
+			t.Errorf("Expected an error page for %s, got %s", test.path, w.Body.String())
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := LogRequests(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c *Card
+		fmt.Fprint(w, c.Characters)
+	})))
+
+	for _, path := range []string{"/card/1", "/api/v1/cards/1"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500 for %s, got %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/cards/1", nil))
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected a JSON error for the API, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestServerErrorsAreNotShown(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+	err := ioutil.WriteFile(cd.HistoricalDataFile(), []byte("2023-08-05,1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd.Cards[2].NextReviewDate = "not a date"
+
+	// Bad data on disk or in a card is an error response, not a crash, and doesn't say where the file is
+	for _, path := range []string{"/historicalstats", "/api/v1/stats/historical", "/cardoverview/bydue", "/schedule"} {
+		w := apiRequest(h, "GET", path, "")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500 for %s, got %d", path, w.Code)
+		}
+		if strings.Contains(w.Body.String(), cd.DataDir) || strings.Contains(w.Body.String(), "not a date") {
+			t.Errorf("Expected the error details to be hidden for %s, got %s", path, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package cards
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mochi-co/kana-tools"
+)
+
+// Facet is one thing to remember about a card, reviewed and scheduled on its own.
+// Most kanji and vocabulary have a meaning and a reading facet, so knowing the meaning
+// doesn't hide a forgotten reading. Radicals, grammar and kana-only vocabulary only have a meaning.
+//
+// The card's own progress fields (Interval, NextReviewDate, LearningStage, ...) are the meaning facet,
+// so cards with a single facet are unchanged. The reading facet is in ReadingReview.
+type Facet string
+
+const (
+	MeaningFacet Facet = "meaning"
+	ReadingFacet Facet = "reading"
+)
+
+var Facets = []Facet{MeaningFacet, ReadingFacet}
+
+func ParseFacet(s string) (Facet, error) {
+	if s == "" {
+		return MeaningFacet, nil
+	}
+	for _, f := range Facets {
+		if s == string(f) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown facet %q, expected one of %v", s, Facets)
+}
+
+// FacetReview is the review state of one facet of a card
+type FacetReview struct {
+	Interval           int           `json:"interval"`          // Hours until next review
+	LearningInterval   int           `json:"learning_interval"` // Hours until next review when in learning stage
+	NextReviewDate     string        `json:"next_review_date"`  // RFC3339 date string
+	TotalTimesReviewed int           `json:"total_times_reviewed"`
+	TotalTimesCorrect  int           `json:"total_times_correct"`
+	Stability          float64       `json:"stability"`
+	Difficulty         float64       `json:"difficulty"`
+	LastReviewDate     string        `json:"last_review_date"` // RFC3339 date string
+	LearningStage      LearningStage `json:"learning_stage"`
+	Lapses             int           `json:"lapses"`
+}
+
+func (r FacetReview) LearningStageString() string {
+	return LearningStageToString(r.LearningStage)
+}
+
+// Hours until the next review, for whichever stage the facet is in
+func (r FacetReview) CurrentInterval() int {
+	if r.LearningStage == Learning {
+		return r.LearningInterval
+	}
+	return r.Interval
+}
+
+// Whether the facet is in a stage that is reviewed, and has a review date
+func (r FacetReview) isScheduled() bool {
+	if r.LearningStage != UpNext && r.LearningStage != Learning && r.LearningStage != Learned {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339, r.NextReviewDate)
+	return err == nil
+}
+
+func (r FacetReview) isDueBefore(t time.Time) bool {
+	if r.NextReviewDate == "" {
+		return false
+	}
+	nrd, err := time.Parse(time.RFC3339, r.NextReviewDate)
+	if err != nil {
+		log.Printf("Invalid NextReviewDate: %s", r.NextReviewDate)
+		return false
+	}
+	return nrd.Before(t)
+}
+
+// The facets the card is reviewed on
+func (c *Card) Facets() []Facet {
+	if c.HasReading() {
+		return Facets
+	}
+	return []Facet{MeaningFacet}
+}
+
+// Whether the card has a reading facet
+func (c *Card) HasReading() bool {
+	if c.Object == "radical" || c.Object == "grammar" || len(c.Readings) == 0 {
+		return false
+	}
+	// Reading kana-only vocabulary is no test of memory
+	return kana.ContainsKanji(c.Characters)
+}
+
+func (c *Card) HasFacet(f Facet) bool {
+	return f == MeaningFacet || (f == ReadingFacet && c.HasReading())
+}
+
+// The review state of a facet.
+// Until the facets are first answered separately, the reading facet is the same as the meaning facet,
+// so cards learned before there were facets carry on with the same schedule for both.
+func (c *Card) Review(f Facet) FacetReview {
+	if f == ReadingFacet && c.ReadingReview != nil {
+		return *c.ReadingReview
+	}
+	return FacetReview{
+		Interval:           c.Interval,
+		LearningInterval:   c.LearningInterval,
+		NextReviewDate:     c.NextReviewDate,
+		TotalTimesReviewed: c.TotalTimesReviewed,
+		TotalTimesCorrect:  c.TotalTimesCorrect,
+		Stability:          c.Stability,
+		Difficulty:         c.Difficulty,
+		LastReviewDate:     c.LastReviewDate,
+		LearningStage:      c.LearningStage,
+		Lapses:             c.Lapses,
+	}
+}
+
+func (c *Card) setMeaningReview(r FacetReview) {
+	c.Interval = r.Interval
+	c.LearningInterval = r.LearningInterval
+	c.NextReviewDate = r.NextReviewDate
+	c.TotalTimesReviewed = r.TotalTimesReviewed
+	c.TotalTimesCorrect = r.TotalTimesCorrect
+	c.Stability = r.Stability
+	c.Difficulty = r.Difficulty
+	c.LastReviewDate = r.LastReviewDate
+	c.LearningStage = r.LearningStage
+	c.Lapses = r.Lapses
+}
+
+func (c *Card) setNextReviewDate(f Facet, date string) {
+	if f == ReadingFacet && c.ReadingReview != nil {
+		c.ReadingReview.NextReviewDate = date
+		return
+	}
+	c.NextReviewDate = date
+}
+
+// Give the reading facet its own review state, before either facet is answered
+func (c *Card) splitFacets() {
+	if c.HasReading() && c.ReadingReview == nil {
+		r := c.Review(MeaningFacet)
+		c.ReadingReview = &r
+	}
+}
+
+// Whether every facet has been learned, so the cards this is a component of can be learned
+func (c *Card) IsLearned() bool {
+	for _, f := range c.Facets() {
+		ls := c.Review(f).LearningStage
+		if ls != Learned && ls != Burned {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether any facet is in the given stage
+func (c *Card) HasFacetInStage(ls LearningStage) bool {
+	for _, f := range c.Facets() {
+		if c.Review(f).LearningStage == ls {
+			return true
+		}
+	}
+	return false
+}
+
+// Answer one facet of the card at the time now.
+// Returns false if the answer was ignored because the facet isn't due yet, or the card doesn't have it.
+func (c *Card) AnswerFacetWith(s Scheduler, f Facet, g Grade, now time.Time) bool {
+	if !c.HasFacet(f) {
+		return false
+	}
+	c.splitFacets()
+	if f == MeaningFacet {
+		return c.AnswerWith(s, g, now)
+	}
+
+	// The schedulers work on a card's own progress fields, so answer the reading on a card of its own
+	rc := &Card{ID: c.ID}
+	rc.setMeaningReview(*c.ReadingReview)
+	answered := rc.AnswerWith(s, g, now)
+	r := rc.Review(MeaningFacet)
+	c.ReadingReview = &r
+	return answered
+}
+
+// One card per facet, with the facet's review state as its progress,
+// so the card filters count reviews rather than cards
+func expandFacets(cards []*Card) []*Card {
+	var expanded []*Card
+	for _, c := range cards {
+		expanded = append(expanded, c)
+		if c.HasReading() {
+			rc := &Card{ID: c.ID, Object: c.Object, Characters: c.Characters, Tags: c.Tags}
+			rc.setMeaningReview(c.Review(ReadingFacet))
+			expanded = append(expanded, rc)
+		}
+	}
+	return expanded
+}
+
+// A card and one of its facets, to be reviewed
+type ReviewItem struct {
+	Card  *Card
+	Facet Facet
+}
+
+// The facets of the cards that are in the given stage and due before t
+func dueReviewItems(cards []*Card, ls LearningStage, t time.Time) []ReviewItem {
+	var items []ReviewItem
+	for _, c := range cards {
+		for _, f := range c.Facets() {
+			r := c.Review(f)
+			if r.LearningStage == ls && r.isDueBefore(t) {
+				items = append(items, ReviewItem{Card: c, Facet: f})
+			}
+		}
+	}
+	return items
+}
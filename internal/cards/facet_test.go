@@ -0,0 +1,162 @@
+package cards
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func createKanjiCard(id int) *Card {
+	c := CreateCard(id, 48, 0, "2020-01-01T00:00:00Z")
+	c.Object = "kanji"
+	c.Characters = "猫"
+	c.Meanings = []Meaning{{Meaning: "cat", Primary: true, AcceptedAnswer: true}}
+	c.Readings = []Reading{{Reading: "ねこ", Primary: true, AcceptedAnswer: true}}
+	c.LearningStage = Learned
+	return c
+}
+
+func TestCardFacets(t *testing.T) {
+	kanji := createKanjiCard(1)
+	if len(kanji.Facets()) != 2 {
+		t.Errorf("Expected a kanji to have 2 facets, got %v", kanji.Facets())
+	}
+
+	radical := createKanjiCard(2)
+	radical.Object = "radical"
+	if len(radical.Facets()) != 1 || radical.HasFacet(ReadingFacet) {
+		t.Errorf("Expected a radical to only have a meaning, got %v", radical.Facets())
+	}
+
+	kanaOnly := createKanjiCard(3)
+	kanaOnly.Object = "vocabulary"
+	kanaOnly.Characters = "ねこ"
+	if kanaOnly.HasReading() {
+		t.Errorf("Expected kana-only vocabulary to only have a meaning")
+	}
+
+	_, err := ParseFacet("spelling")
+	if err == nil {
+		t.Errorf("Expected an error for an unknown facet")
+	}
+	if f, _ := ParseFacet(""); f != MeaningFacet {
+		t.Errorf("Expected the facet to default to meaning, got %s", f)
+	}
+}
+
+func TestAnswerFacetsSeparately(t *testing.T) {
+	c := createKanjiCard(1)
+	dependent := CreateCard(2, 0, 0, "")
+	dependent.ComponentSubjectIDs = []int{1}
+	cd := CreateCardDataFromSlice([]*Card{c, dependent})
+	cd.UpdateCardData()
+	if dependent.LearningStage != Available {
+		t.Errorf("Expected the dependent card to be available, got %d", dependent.LearningStage)
+	}
+
+	// Before either facet is answered, the reading has the meaning's schedule
+	if c.ReadingReview != nil || c.Review(ReadingFacet).Interval != 48 {
+		t.Errorf("Expected the reading to follow the meaning, got %v", c.ReadingReview)
+	}
+
+	if !c.AnswerFacetWith(DoublingScheduler{}, MeaningFacet, Good, time.Now()) {
+		t.Fatalf("Expected the meaning to be answered")
+	}
+	if c.Interval != 96 || c.ReadingReview == nil || c.ReadingReview.Interval != 48 {
+		t.Errorf("Expected only the meaning interval to double, got %d and %v", c.Interval, c.ReadingReview)
+	}
+
+	if !c.AnswerFacetWith(DoublingScheduler{}, ReadingFacet, Again, time.Now()) {
+		t.Fatalf("Expected the reading to be answered")
+	}
+	if c.LearningStage != Learned || c.ReadingReview.LearningStage != Learning || c.ReadingReview.Interval != 24 {
+		t.Errorf("Expected only the reading to be relearned, got %d and %v", c.LearningStage, c.ReadingReview)
+	}
+	if c.IsLearned() {
+		t.Errorf("Expected the card not to be learned while its reading is being relearned")
+	}
+
+	cd.UpdateCardData()
+	if dependent.LearningStage != Unavailable {
+		t.Errorf("Expected the dependent card to be unavailable until both facets are learned, got %d", dependent.LearningStage)
+	}
+
+	// The reading isn't due again for a while, so answering it again is ignored
+	if c.AnswerFacetWith(DoublingScheduler{}, ReadingFacet, Good, time.Now()) {
+		t.Errorf("Expected the answer to be ignored")
+	}
+}
+
+func TestSrsReviewsEachFacet(t *testing.T) {
+	cd := createStoreCardData(t, 0)
+	cd.Cards[1] = createKanjiCard(1)
+
+	srsData := cd.GetNextSrsCard()
+	if srsData.DueCount != 2 || srsData.Card == nil || srsData.Card.ID != 1 {
+		t.Fatalf("Expected both facets of card 1 to be due, got %d", srsData.DueCount)
+	}
+
+	result, err := cd.AnswerCard(1, srsData.Facet, Good, 0)
+	if err != nil || !result.Answered || result.Facet != srsData.Facet {
+		t.Fatalf("Expected the %s to be answered, got %v %v", srsData.Facet, result, err)
+	}
+
+	next := cd.GetNextSrsCard()
+	if next.DueCount != 1 || next.Facet == srsData.Facet {
+		t.Errorf("Expected the other facet to be due, got %d %s", next.DueCount, next.Facet)
+	}
+
+	entries, err := cd.GetCardReviewLog(1)
+	if err != nil || len(entries) != 1 || entries[0].Facet != srsData.Facet {
+		t.Errorf("Expected the review to be logged for the %s, got %v %v", srsData.Facet, entries, err)
+	}
+}
+
+func TestUpNextFacets(t *testing.T) {
+	c := createKanjiCard(1)
+	c.Interval = 0
+	c.LearningStage = UpNext
+	c.NextReviewDate = "1970-01-01T00:00:00Z"
+	c.QueuedToLearn = true
+	cd := createStoreCardData(t, 0)
+	cd.Cards[1] = c
+	cd.QueueUpNextCards(1)
+
+	_, err := cd.AnswerCard(1, MeaningFacet, Good, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cd.UpNext) != 1 {
+		t.Fatalf("Expected the card to stay up next until its reading is answered")
+	}
+	srsData := cd.GetNextSrsCard()
+	if srsData.Facet != ReadingFacet || srsData.Review.LearningStage != UpNext {
+		t.Errorf("Expected the reading to be up next, got %s %d", srsData.Facet, srsData.Review.LearningStage)
+	}
+
+	_, err = cd.AnswerCard(1, ReadingFacet, Good, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cd.UpNext) != 0 {
+		t.Errorf("Expected the card to leave the up next queue")
+	}
+}
+
+func TestApiAnswerFacet(t *testing.T) {
+	cd, h := createTestRouter(t, 1)
+	cd.Cards[2] = createKanjiCard(2)
+
+	w := apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": 1, "facet": "reading", "grade": "good"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a card without a reading, got %d", w.Code)
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": 2, "facet": "reading", "grade": "good"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %s", w.Code, w.Body.String())
+	}
+	if cd.Cards[2].Interval != 48 || cd.Cards[2].ReadingReview.Interval != 96 {
+		t.Errorf("Expected only the reading to be answered, got %d and %v", cd.Cards[2].Interval, cd.Cards[2].ReadingReview)
+	}
+}
@@ -0,0 +1,186 @@
+package cards
+
+import (
+	"math"
+	"time"
+)
+
+// FsrsScheduler schedules learned cards with the FSRS v4 memory model.
+// Each card tracks a stability (days until recall probability drops to 90%) and a difficulty (1-10).
+// The next interval is chosen so that the card is reviewed when recall probability reaches DesiredRetention.
+//
+// Cards still go through the normal Up Next and Learning stages.
+// FSRS takes over once a card has graduated to the Learned stage.
+// https://github.com/open-spaced-repetition/fsrs4anki/wiki/The-Algorithm
+type FsrsScheduler struct {
+	SrsSettings
+	DesiredRetention float64
+	Weights          [17]float64
+}
+
+// Default FSRS v4 parameters
+var DefaultFsrsWeights = [17]float64{
+	0.4, 0.6, 2.4, 5.8,
+	4.93, 0.94, 0.86, 0.01,
+	1.49, 0.14, 0.94,
+	2.18, 0.05, 0.34, 1.26,
+	0.29, 2.61,
+}
+
+func NewFsrsScheduler() FsrsScheduler {
+	return FsrsScheduler{
+		DesiredRetention: 0.9,
+		Weights:          DefaultFsrsWeights,
+	}
+}
+
+func (s FsrsScheduler) Name() string {
+	return "fsrs"
+}
+
+func (s FsrsScheduler) ProcessAnswer(c *Card, g Grade, now time.Time) {
+	if g == Again {
+		s.processIncorrectAnswer(c, now)
+		return
+	}
+
+	st := s.Settings()
+	if c.LearningStage == Learning {
+		learningStepCorrect(c, g)
+	} else if c.LearningStage == Learned {
+		s.updateMemoryState(c, g, now)
+		c.Interval = s.intervalHours(c.Stability)
+
+		if c.Interval >= st.BurnInterval {
+			c.LearningStage = Burned
+		}
+	} else if c.LearningStage == UpNext {
+		s.updateMemoryState(c, g, now)
+		upNextCorrect(c, g, st)
+
+		// Easy cards skip the learning stage, so use the FSRS interval straight away
+		if c.LearningStage == Learned {
+			c.Interval = s.intervalHours(c.Stability)
+		}
+	}
+
+	c.IncrementReviewCount()
+	c.IncrementCorrectAnswerCount()
+	c.SetNextReviewDate(now)
+}
+
+func (s FsrsScheduler) processIncorrectAnswer(c *Card, now time.Time) {
+	st := s.Settings()
+	if c.LearningStage == Learning {
+		learningStepIncorrect(c, st, now)
+	} else if c.LearningStage == Learned {
+		s.updateMemoryState(c, Again, now)
+
+		// The post-lapse stability becomes the interval the card returns to once it is relearned.
+		c.Interval = s.intervalHours(c.Stability)
+		c.LearningStage = Learning
+		c.LearningInterval = st.InitialLearningInterval
+
+		c.IncrementReviewCount()
+		c.SetNextFailedReviewDate(now)
+	} else if c.LearningStage == UpNext {
+		upNextIncorrect(c)
+	}
+}
+
+func (s FsrsScheduler) updateMemoryState(c *Card, g Grade, now time.Time) {
+	if c.Stability == 0 && c.LearningStage == UpNext {
+		c.Stability = s.initialStability(g)
+		c.Difficulty = s.initialDifficulty(g)
+		c.LastReviewDate = now.Format(time.RFC3339)
+		return
+	}
+
+	// Cards that were learned with another scheduler have no memory state yet.
+	// Seed it from the current interval so that switching schedulers doesn't reset progress.
+	if c.Stability == 0 {
+		c.Stability = math.Max(float64(c.Interval)/24, s.initialStability(g))
+		c.Difficulty = s.initialDifficulty(Good)
+	}
+
+	r := s.retrievability(c.elapsedDays(now), c.Stability)
+	c.Difficulty = s.nextDifficulty(c.Difficulty, g)
+	if g == Again {
+		c.Stability = s.forgetStability(c.Difficulty, c.Stability, r)
+	} else {
+		c.Stability = s.recallStability(c.Difficulty, c.Stability, r, g)
+	}
+	c.LastReviewDate = now.Format(time.RFC3339)
+}
+
+// Probability of recall after t days with stability s
+func (s FsrsScheduler) retrievability(t float64, stability float64) float64 {
+	return math.Pow(1+t/(9*stability), -1)
+}
+
+// Number of hours until recall probability drops to the desired retention.
+// Never less than a day, as anything shorter is handled by the learning stage.
+func (s FsrsScheduler) intervalHours(stability float64) int {
+	days := 9 * stability * (1/s.DesiredRetention - 1)
+	hours := int(math.Round(days * 24))
+	if hours < 24 {
+		hours = 24
+	}
+	return hours
+}
+
+func (s FsrsScheduler) initialStability(g Grade) float64 {
+	return math.Max(s.Weights[g-1], 0.1)
+}
+
+func (s FsrsScheduler) initialDifficulty(g Grade) float64 {
+	return clampDifficulty(s.Weights[4] - s.Weights[5]*float64(g-3))
+}
+
+func (s FsrsScheduler) nextDifficulty(d float64, g Grade) float64 {
+	next := d - s.Weights[6]*float64(g-3)
+	// Mean reversion towards the initial difficulty of a "Good" answer
+	next = s.Weights[7]*s.initialDifficulty(Good) + (1-s.Weights[7])*next
+	return clampDifficulty(next)
+}
+
+func (s FsrsScheduler) recallStability(d float64, stability float64, r float64, g Grade) float64 {
+	hardPenalty := 1.0
+	if g == Hard {
+		hardPenalty = s.Weights[15]
+	}
+	easyBonus := 1.0
+	if g == Easy {
+		easyBonus = s.Weights[16]
+	}
+
+	return stability * (1 + math.Exp(s.Weights[8])*
+		(11-d)*
+		math.Pow(stability, -s.Weights[9])*
+		(math.Exp((1-r)*s.Weights[10])-1)*
+		hardPenalty*
+		easyBonus)
+}
+
+func (s FsrsScheduler) forgetStability(d float64, stability float64, r float64) float64 {
+	return s.Weights[11] *
+		math.Pow(d, -s.Weights[12]) *
+		(math.Pow(stability+1, s.Weights[13]) - 1) *
+		math.Exp((1-r)*s.Weights[14])
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(math.Max(d, 1), 10)
+}
+
+// Days since the card was last reviewed.
+// Falls back to the current interval for cards that have no recorded review date.
+func (c *Card) elapsedDays(now time.Time) float64 {
+	if c.LastReviewDate != "" {
+		t, err := time.Parse(time.RFC3339, c.LastReviewDate)
+		if err == nil {
+			return math.Max(now.Sub(t).Hours()/24, 0)
+		}
+	}
+	return float64(c.Interval) / 24
+}
@@ -0,0 +1,98 @@
+package cards
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Reviews of learned facets are fuzzed, so cards learned together don't stay due together forever.
+// The review can move by up to a tenth of the interval either way, capped at a week.
+// Within that window it goes to the least loaded hour, or for long intervals the least loaded day,
+// counting the reviews already due then. Ties are broken at random.
+// The interval itself is left alone, so only the review date is fuzzed.
+
+const fuzzFraction = 0.1
+const maxFuzzHours = 7 * 24
+
+// Hours either side of the due date a review with the given interval can be moved
+func fuzzHours(interval int) int {
+	h := int(float64(interval) * fuzzFraction)
+	if h > maxFuzzHours {
+		h = maxFuzzHours
+	}
+	return h
+}
+
+// A time a review could be moved to, and the span around it that counts towards its load
+type fuzzSlot struct {
+	At    time.Time
+	Start time.Time
+	End   time.Time
+	Load  int // Reviews already due between Start and End
+}
+
+// The times the review of a facet due at due could be moved to. Hours for short intervals, study days for long ones.
+func fuzzSlots(due time.Time, interval int, b DayBoundary) []fuzzSlot {
+	var slots []fuzzSlot
+	h := fuzzHours(interval)
+	if h < 24 {
+		for i := -h; i <= h; i++ {
+			t := due.Add(time.Duration(i) * time.Hour)
+			slots = append(slots, fuzzSlot{At: t, Start: t, End: t.Add(time.Hour)})
+		}
+		return slots
+	}
+	for i := -h / 24; i <= h/24; i++ {
+		t := due.AddDate(0, 0, i)
+		slots = append(slots, fuzzSlot{At: t, Start: b.DayStart(t), End: b.NextDayStart(t)})
+	}
+	return slots
+}
+
+// Move the review of a facet that has just been answered to the least loaded time near when it's due.
+// Caller must hold the lock.
+func (cd *CardData) fuzzReviewDate(c *Card, f Facet) {
+	if cd.GetScheduler().Settings().NoFuzz {
+		return
+	}
+	r := c.Review(f)
+	if r.LearningStage != Learned {
+		return
+	}
+	due, err := time.Parse(time.RFC3339, r.NextReviewDate)
+	if err != nil {
+		return
+	}
+	slots := fuzzSlots(due, r.Interval, cd.DayBoundary)
+	if len(slots) < 2 {
+		return
+	}
+
+	// Forecast the reviews due in each slot, leaving out the facet being moved
+	for _, o := range filterOutCardsByTag(cd.ToList(), "suspended") {
+		for _, of := range o.Facets() {
+			if o.ID == c.ID && of == f {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, o.Review(of).NextReviewDate)
+			if err != nil {
+				continue
+			}
+			for i := range slots {
+				if !t.Before(slots[i].Start) && t.Before(slots[i].End) {
+					slots[i].Load++
+				}
+			}
+		}
+	}
+
+	var best []fuzzSlot
+	for _, s := range slots {
+		if len(best) == 0 || s.Load < best[0].Load {
+			best = []fuzzSlot{s}
+		} else if s.Load == best[0].Load {
+			best = append(best, s)
+		}
+	}
+	c.setNextReviewDate(f, best[rand.Intn(len(best))].At.Format(time.RFC3339))
+}
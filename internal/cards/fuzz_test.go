@@ -0,0 +1,102 @@
+package cards
+
+import (
+	"testing"
+	"time"
+)
+
+// Card 1 is learned and due at due, and every other card is due at one of busy
+func createFuzzCardData(interval int, due time.Time, busy []time.Time) *CardData {
+	c := CreateCard(1, interval, 0, due.Format(time.RFC3339))
+	c.LearningStage = Learned
+	cs := []*Card{c}
+	for i, t := range busy {
+		o := CreateCard(i+2, 48, 0, t.Format(time.RFC3339))
+		o.LearningStage = Learned
+		cs = append(cs, o)
+	}
+	return CreateCardDataFromSlice(cs)
+}
+
+func TestFuzzHours(t *testing.T) {
+	for _, tc := range []struct{ interval, hours int }{{24, 2}, {96, 9}, {8760, maxFuzzHours}} {
+		if h := fuzzHours(tc.interval); h != tc.hours {
+			t.Errorf("Expected %d hours of fuzz for an interval of %d, got %d", tc.hours, tc.interval, h)
+		}
+	}
+}
+
+func TestFuzzPicksQuietestHour(t *testing.T) {
+	due := time.Now().Truncate(time.Hour).Add(96 * time.Hour)
+	quiet := due.Add(5 * time.Hour)
+
+	// Every hour in the window is busy but one
+	var busy []time.Time
+	for i := -9; i <= 9; i++ {
+		if i != 5 {
+			busy = append(busy, due.Add(time.Duration(i)*time.Hour))
+		}
+	}
+	cd := createFuzzCardData(96, due, busy)
+
+	cd.fuzzReviewDate(cd.Cards[1], MeaningFacet)
+	if d := cd.Cards[1].NextReviewDate; d != quiet.Format(time.RFC3339) {
+		t.Errorf("Expected the review to move to %s, got %s", quiet.Format(time.RFC3339), d)
+	}
+
+	// Suspended cards aren't reviewed, so don't count
+	cd = createFuzzCardData(96, due, []time.Time{due})
+	cd.Cards[2].TagSuspended()
+	for i := 0; i < 20; i++ {
+		cd.Cards[1].NextReviewDate = due.Format(time.RFC3339)
+		cd.fuzzReviewDate(cd.Cards[1], MeaningFacet)
+		moved, _ := time.Parse(time.RFC3339, cd.Cards[1].NextReviewDate)
+		if moved.Before(due.Add(-9*time.Hour)) || moved.After(due.Add(9*time.Hour)) {
+			t.Fatalf("Expected the review to stay within 9 hours of %s, got %s", due, moved)
+		}
+	}
+}
+
+func TestFuzzPicksQuietestDay(t *testing.T) {
+	due := time.Now().Truncate(time.Hour).AddDate(0, 0, 100)
+	quiet := due.AddDate(0, 0, -3)
+
+	var busy []time.Time
+	for i := -7; i <= 7; i++ {
+		if i != -3 {
+			busy = append(busy, due.AddDate(0, 0, i))
+		}
+	}
+	cd := createFuzzCardData(2400, due, busy)
+
+	cd.fuzzReviewDate(cd.Cards[1], MeaningFacet)
+	if d := cd.Cards[1].NextReviewDate; d != quiet.Format(time.RFC3339) {
+		t.Errorf("Expected the review to move to %s, got %s", quiet.Format(time.RFC3339), d)
+	}
+	if cd.Cards[1].Interval != 2400 {
+		t.Errorf("Expected the interval to be left alone, got %d", cd.Cards[1].Interval)
+	}
+}
+
+func TestNoFuzz(t *testing.T) {
+	due := time.Now().Truncate(time.Hour).Add(96 * time.Hour)
+	cd := createFuzzCardData(96, due, []time.Time{due})
+	cd.Scheduler = DoublingScheduler{SrsSettings: SrsSettings{NoFuzz: true}}
+
+	cd.fuzzReviewDate(cd.Cards[1], MeaningFacet)
+	if d := cd.Cards[1].NextReviewDate; d != due.Format(time.RFC3339) {
+		t.Errorf("Expected the review to stay at %s, got %s", due.Format(time.RFC3339), d)
+	}
+}
+
+func TestAnswerFuzzesLearnedReviews(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	cd.AnswerCard(1, MeaningFacet, Good, 0)
+
+	// Interval 96, so up to 9 hours either side
+	due := time.Now().Add(96 * time.Hour).Round(time.Hour)
+	moved, err := time.Parse(time.RFC3339, cd.Cards[1].NextReviewDate)
+	if err != nil || moved.Before(due.Add(-9*time.Hour)) || moved.After(due.Add(9*time.Hour)) {
+		t.Errorf("Expected the review to be within 9 hours of %s, got %s", due, cd.Cards[1].NextReviewDate)
+	}
+}
@@ -2,6 +2,7 @@ package cards
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/ioutil"
@@ -9,87 +10,131 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/mochi-co/kana-tools"
 )
 
-func SetupRoutes(cd *CardData) {
-	cd.SetupFuncMap()
+// A handler method on CardData, e.g. (*CardData).IndexHandler.
+// A returned error is responded to by serveError, so handlers must not write anything before failing.
+type cardDataHandler func(cd *CardData, w http.ResponseWriter, r *http.Request) error
 
-	log.Printf("Data dir: %s", cd.DataDir)
+// Picks the card data a request is handled with
+type profileFunc func(r *http.Request) (*CardData, error)
 
-	r := mux.NewRouter()
+func singleProfile(cd *CardData) profileFunc {
+	return func(r *http.Request) (*CardData, error) {
+		return cd, nil
+	}
+}
 
-	r.HandleFunc("/", cd.IndexHandler)
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(cd.StaticDir))))
-	r.HandleFunc("/stylesheet.css", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "static/css/stylesheet.css")
-	})
-	r.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "static/img/icon.png")
-	})
-	r.PathPrefix("/img/").Handler(http.FileServer(http.Dir(cd.StaticDir)))
+// Routes for every page. base has the data and static directories, and renders errors for requests without a profile.
+func NewRouter(base *CardData, profile profileFunc) *mux.Router {
+	dataDir, staticFiles := base.DataDir, http.FS(base.StaticFiles())
+	handle := func(h cardDataHandler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			cd, err := profile(r)
+			if err != nil {
+				base.serveError(w, r, err)
+				return
+			}
+			err = h(cd, w, r)
+			if err != nil {
+				cd.serveError(w, r, err)
+			}
+		}
+	}
 
-	// Strip the /data prefix from the path and serve the file from the data directory
-	r.PathPrefix("/data/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(cd.DataDir, r.URL.Path[6:]))
+	r := mux.NewRouter()
+	r.Use(CSRFProtect)
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base.serveError(w, r, httpError(http.StatusNotFound, errors.New("no such page")))
 	})
 
-	r.HandleFunc("/card/new", cd.CardNewHandler)
-	r.HandleFunc("/card/{id}", cd.CardHandler)
-	r.HandleFunc("/card/{id}/raw", cd.CardRawHandler)
-	r.HandleFunc("/card/{id}/json", cd.CardJsonHandler)
-	r.HandleFunc("/card/{id}/edit", cd.CardJsonEditHandler)
-	r.HandleFunc("/card/{id}/edit/save", cd.CardJsonEditSaveHandler)
-	r.HandleFunc("/card/{id}/edit/characterimageupload", cd.CardCharacterImageUploadHandler)
-	r.HandleFunc("/card/{id}/delete", cd.CardDeleteHandler)
-	r.HandleFunc("/card/{id}/tagsuspended", cd.CardTagSuspendedHandler)
-	r.HandleFunc("/card/{id}/addtoqueue", cd.CardAddToQueueHandler)
+	r.HandleFunc("/", handle((*CardData).IndexHandler))
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(staticFiles)))
+	r.HandleFunc("/stylesheet.css", serveStaticFile(staticFiles, "css/stylesheet.css"))
+	r.HandleFunc("/favicon.ico", serveStaticFile(staticFiles, "img/icon.png"))
+	r.PathPrefix("/img/").Handler(http.FileServer(staticFiles))
 
-	r.HandleFunc("/cardoverview", cd.OverviewByDueHandler)
-	r.HandleFunc("/cardoverview/bylearningstage", cd.OverviewByLearningStageHandler)
-	r.HandleFunc("/cardoverview/bylevel", cd.OverviewByLevelHandler)
-	r.HandleFunc("/cardoverview/bydue", cd.OverviewByDueHandler)
-	r.HandleFunc("/cardoverview/bytype", cd.OverviewByTypeHandler)
-	r.HandleFunc("/cardoverview/bypartsofspeech", cd.OverviewByPartsOfSpeechHandler)
-	r.HandleFunc("/cardoverview/byreviewperformance", cd.OverviewByReviewPerformanceHandler)
-	r.HandleFunc("/cardoverview/bytag", cd.OverviewByTagHandler)
-	r.HandleFunc("/cardoverview/simulate/{correctRate}/{newCardsPerDay}", cd.OverviewSimulateHandler)
-	r.HandleFunc("/cardoverview/debug", cd.OverviewDebugHandler)
-
-	r.HandleFunc("/textanalysis", cd.TextAnalysisHandler)
-	r.HandleFunc("/textanalysis/new", cd.TextAnalysisNewHandler)
-	r.HandleFunc("/textanalysis/new/submit", cd.TextAnalysisNewSubmitHandler)
-	r.HandleFunc("/textanalysis/{id}", cd.TextAnalysisIdHandler)
-	r.HandleFunc("/textanalysis/{id}/delete", cd.TextAnalysisIdDeleteHandler)
-
-	r.HandleFunc("/srs", cd.SrsHandler)
-	r.HandleFunc("/srs/correct/{id}", cd.SrsCorrectHandler)
-	r.HandleFunc("/srs/incorrect/{id}", cd.SrsIncorrectHandler)
-	r.HandleFunc("/srs/addupnextcards/{n}", cd.SrsAddUpNextCardsHandler)
-
-	r.HandleFunc("/schedule", cd.ScheduleHandler)
-
-	r.HandleFunc("/search", cd.SearchHandler)
-
-	r.HandleFunc("/dictionarysearch", cd.DictionarySearchHandler)
-	r.HandleFunc("/dictionaryentries", cd.DictionaryEntriesHandler)
-	r.HandleFunc("/adddictionaryascard/{id}", cd.AddDictionaryAsCardHandler)
-
-	r.HandleFunc("/other", cd.OtherHandler)
-	r.HandleFunc("/kanjifrequency", cd.KanjiFrequencyHandler)
-	r.HandleFunc("/historicalstats", cd.HistoricalStatsHandler)
-
-	r.HandleFunc("/debug/addtoupnextqueue/{id}", cd.DebugAddToUpNextQueueHandler)
+	// Strip the /data prefix from the path and serve the file from the data directory.
+	// Users' profiles and passwords are private.
+	r.PathPrefix("/data/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path[6:])
+		if name == "/users.json" || strings.HasPrefix(name, "/users/") {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(dataDir, name))
+	})
 
-	http.ListenAndServe(":8080", r)
+	r.HandleFunc("/card/new", handle((*CardData).CardNewHandler)).Methods("POST")
+	r.HandleFunc("/card/{id:[0-9]+}", handle((*CardData).CardHandler))
+	r.HandleFunc("/card/{id}/raw", handle((*CardData).CardRawHandler))
+	r.HandleFunc("/card/{id}/json", handle((*CardData).CardJsonHandler))
+	r.HandleFunc("/card/{id}/revlog", handle((*CardData).CardReviewLogHandler))
+	r.HandleFunc("/card/{id}/edit", handle((*CardData).CardJsonEditHandler))
+	r.HandleFunc("/card/{id}/edit/save", handle((*CardData).CardJsonEditSaveHandler)).Methods("POST")
+	r.HandleFunc("/card/{id}/edit/characterimageupload", handle((*CardData).CardCharacterImageUploadHandler)).Methods("POST")
+	r.HandleFunc("/card/{id}/delete", handle((*CardData).CardDeleteHandler)).Methods("POST")
+	r.HandleFunc("/card/{id}/tagsuspended", handle((*CardData).CardTagSuspendedHandler)).Methods("POST")
+	r.HandleFunc("/card/{id}/addtoqueue", handle((*CardData).CardAddToQueueHandler)).Methods("POST")
+
+	r.HandleFunc("/cardoverview", handle((*CardData).OverviewByDueHandler))
+	r.HandleFunc("/cardoverview/bylearningstage", handle((*CardData).OverviewByLearningStageHandler))
+	r.HandleFunc("/cardoverview/bylevel", handle((*CardData).OverviewByLevelHandler))
+	r.HandleFunc("/cardoverview/bydue", handle((*CardData).OverviewByDueHandler))
+	r.HandleFunc("/cardoverview/bytype", handle((*CardData).OverviewByTypeHandler))
+	r.HandleFunc("/cardoverview/bypartsofspeech", handle((*CardData).OverviewByPartsOfSpeechHandler))
+	r.HandleFunc("/cardoverview/byreviewperformance", handle((*CardData).OverviewByReviewPerformanceHandler))
+	r.HandleFunc("/cardoverview/leeches", handle((*CardData).OverviewLeechesHandler))
+	r.HandleFunc("/cardoverview/bytag", handle((*CardData).OverviewByTagHandler))
+	r.HandleFunc("/cardoverview/simulate/{correctRate}/{newCardsPerDay}", handle((*CardData).OverviewSimulateHandler))
+	r.HandleFunc("/cardoverview/debug", handle((*CardData).OverviewDebugHandler))
+
+	r.HandleFunc("/textanalysis", handle((*CardData).TextAnalysisHandler))
+	r.HandleFunc("/textanalysis/new", handle((*CardData).TextAnalysisNewHandler))
+	r.HandleFunc("/textanalysis/new/submit", handle((*CardData).TextAnalysisNewSubmitHandler)).Methods("POST")
+	r.HandleFunc("/textanalysis/{id}", handle((*CardData).TextAnalysisIdHandler))
+	r.HandleFunc("/textanalysis/{id}/delete", handle((*CardData).TextAnalysisIdDeleteHandler)).Methods("POST")
+
+	r.HandleFunc("/srs", handle((*CardData).SrsHandler))
+	r.HandleFunc("/srs/correct/{id}", handle((*CardData).SrsCorrectHandler)).Methods("POST")
+	r.HandleFunc("/srs/incorrect/{id}", handle((*CardData).SrsIncorrectHandler)).Methods("POST")
+	r.HandleFunc("/srs/answer/{id}/{grade}", handle((*CardData).SrsAnswerHandler)).Methods("POST")
+	r.HandleFunc("/srs/undo", handle((*CardData).SrsUndoHandler)).Methods("POST")
+	r.HandleFunc("/srs/addupnextcards/{n}", handle((*CardData).SrsAddUpNextCardsHandler)).Methods("POST")
+
+	r.HandleFunc("/schedule", handle((*CardData).ScheduleHandler))
+	r.HandleFunc("/vacation/start", handle((*CardData).VacationStartHandler)).Methods("POST")
+	r.HandleFunc("/vacation/end", handle((*CardData).VacationEndHandler)).Methods("POST")
+
+	r.HandleFunc("/search", handle((*CardData).SearchHandler))
+
+	r.HandleFunc("/dictionarysearch", handle((*CardData).DictionarySearchHandler))
+	r.HandleFunc("/dictionaryentries", handle((*CardData).DictionaryEntriesHandler))
+	r.HandleFunc("/adddictionaryascard/{id}", handle((*CardData).AddDictionaryAsCardHandler)).Methods("POST")
+
+	r.HandleFunc("/other", handle((*CardData).OtherHandler))
+	r.HandleFunc("/kanjifrequency", handle((*CardData).KanjiFrequencyHandler))
+	r.HandleFunc("/historicalstats", handle((*CardData).HistoricalStatsHandler))
+
+	r.HandleFunc("/backups", handle((*CardData).BackupsHandler))
+	r.HandleFunc("/backups/{name}", handle((*CardData).BackupHandler))
+	r.HandleFunc("/backups/{name}/restore", handle((*CardData).BackupRestoreHandler)).Methods("POST")
+
+	r.HandleFunc("/debug/addtoupnextqueue/{id}", handle((*CardData).DebugAddToUpNextQueueHandler)).Methods("POST")
+
+	// Describes every route, including these pages. Served without logging in, so clients can be generated from it.
+	// Keep it up to date when adding routes, TestOpenAPIDocumentsEveryRoute fails otherwise.
+	r.HandleFunc(apiPrefix+"/openapi.json", serveStaticFile(staticFiles, "openapi.json")).Methods("GET")
+	apiRoutes(r, handle)
+
+	return r
 }
 
 func (cd *CardData) SetupFuncMap() {
@@ -125,77 +170,94 @@ func (cd *CardData) SetupFuncMap() {
 	cd.FuncMap = funcMap
 }
 
-func (cd *CardData) doTemplate(w http.ResponseWriter, r *http.Request, templateName string, data interface{}) {
-	htmlDir := filepath.Join(cd.StaticDir, "html")
-	templatemainFile := filepath.Join(htmlDir, "templatemain.html")
-	templateFile := filepath.Join(htmlDir, templateName)
-	// Load template with custom function map
-	t, err := template.New("templatemain.html").Funcs(cd.FuncMap).ParseFiles(templatemainFile, templateFile)
-	if err != nil {
-		panic(err)
+func (cd *CardData) doTemplate(w http.ResponseWriter, r *http.Request, templateName string, data interface{}) error {
+	return cd.renderTemplate(w, r, http.StatusOK, templateName, data)
+}
+
+// Serve one static file, whatever the request path
+func serveStaticFile(files http.FileSystem, name string) http.HandlerFunc {
+	fileServer := http.FileServer(files)
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+		r.URL.Path = "/" + name
+		fileServer.ServeHTTP(w, r)
 	}
+}
 
-	t.Execute(w, data)
+// The {id} in the route
+func routeID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, httpError(http.StatusBadRequest, fmt.Errorf("invalid ID %q", mux.Vars(r)["id"]))
+	}
+	return id, nil
 }
 
-func (cd *CardData) IndexHandler(w http.ResponseWriter, r *http.Request) {
-	cd.doTemplate(w, r, "index.html", nil)
+func (cd *CardData) IndexHandler(w http.ResponseWriter, r *http.Request) error {
+	return cd.doTemplate(w, r, "index.html", nil)
 }
 
 func (cd *CardData) ServeFile(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Serving file: %s", r.URL.Path[1:])
-	http.ServeFile(w, r, filepath.Join(cd.StaticDir, r.URL.Path[1:]))
+	http.FileServer(http.FS(cd.StaticFiles())).ServeHTTP(w, r)
 }
 
-func (cd *CardData) CardHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	c := cd.GetCard(id)
-	dt := c.GetDataTree(cd)
+	c, err := s.getCardOrError(id)
+	if err != nil {
+		return err
+	}
+	dt := c.GetDataTree(s)
 
-	cd.doTemplate(w, r, "card.html", dt)
+	return s.doTemplate(w, r, "card.html", dt)
 }
 
-func (cd *CardData) CardRawHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardRawHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	c := cd.GetCard(id)
-	dt := c.GetDataTree(cd)
+	c, err := s.getCardOrError(id)
+	if err != nil {
+		return err
+	}
+	dt := c.GetDataTree(s)
+
+	return writeJSON(w, http.StatusOK, dt)
+}
 
-	// Convert the card data tree to json and write it to the response
-	json, err := json.Marshal(dt)
+func (cd *CardData) CardJsonHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	id, err := routeID(r)
+	if err != nil {
+		return err
+	}
+	c, err := s.getCardOrError(id)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(json)
+
+	return writeJSON(w, http.StatusOK, c)
 }
 
-func (cd *CardData) CardJsonHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardReviewLogHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	c := cd.GetCard(id)
 
-	// Convert the card data tree to json and write it to the response
-	json, err := json.Marshal(c)
+	entries, err := cd.GetCardReviewLog(id)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(json)
+	return writeJSON(w, http.StatusOK, entries)
 }
 
 type CardEditData struct {
@@ -211,16 +273,18 @@ type CardEditData struct {
 	SuggestedComponents []*Card
 }
 
-func (cd *CardData) CardJsonEditHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardJsonEditHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	c := cd.GetCard(id)
-	dt := c.GetDataTree(cd)
-	suggestedComponents := filterCardsByCharacters(cd.ToList(), c.Characters)
+	c, err := s.getCardOrError(id)
+	if err != nil {
+		return err
+	}
+	dt := c.GetDataTree(s)
+	suggestedComponents := filterCardsByCharacters(s.ToList(), c.Characters)
 	suggestedComponents = removeCard(suggestedComponents, c)
 
 	editData := CardEditData{
@@ -228,22 +292,20 @@ func (cd *CardData) CardJsonEditHandler(w http.ResponseWriter, r *http.Request)
 		SuggestedComponents: suggestedComponents,
 	}
 
-	cd.doTemplate(w, r, "cardjsonedit.html", editData)
+	return s.doTemplate(w, r, "cardjsonedit.html", editData)
 }
 
-func (cd *CardData) CardJsonEditSaveHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardJsonEditSaveHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	log.Printf("Saving card %d", id)
 
 	// Get the json data from the request
 	jsonData, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		panic(err)
+		return httpError(http.StatusBadRequest, err)
 	}
 
 	// Log the json data
@@ -253,78 +315,60 @@ func (cd *CardData) CardJsonEditSaveHandler(w http.ResponseWriter, r *http.Reque
 	var c Card
 	err = json.Unmarshal(jsonData, &c)
 	if err != nil {
-		log.Printf("Error unmarshalling json data: %s", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return httpError(http.StatusBadRequest, err)
 	}
-
-	// Validate the NextReviewDate is a valid date
-	if c.NextReviewDate != "" {
-		_, err = time.Parse(time.RFC3339, c.NextReviewDate)
-		if err != nil {
-			log.Printf("Error parsing NextReviewDate: %s", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
+	err = validateCard(&c)
+	if err != nil {
+		return err
 	}
 
 	// Save the card
-	cd.Cards[id] = &c
-	cd.UpdateCardData()
-	cd.SaveCardMap()
+	c.ID = id
+	err = cd.SaveCard(id, &c)
+	if err != nil {
+		return err
+	}
 
-	// Send an ok response
-	w.WriteHeader(http.StatusOK)
+	return nil
 }
 
-func (cd *CardData) CardCharacterImageUploadHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardCharacterImageUploadHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	log.Printf("Uploading image for card %d", id)
 
 	// Get the image data from the POST request
 	imageData, _, err := r.FormFile("file")
 	if err != nil {
-		log.Printf("Error getting image data: %s", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return httpError(http.StatusBadRequest, err)
 	}
 
 	// Extract the image data
 	imageDataBytes, err := ioutil.ReadAll(imageData)
 	if err != nil {
-		log.Printf("Error reading image data: %s", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
 
-	c := cd.GetCard(id)
-	filename := fmt.Sprintf("%d_characterimage.png", c.ID)
+	filename := fmt.Sprintf("%d_characterimage.png", id)
 	file, err := os.Create(filepath.Join(cd.DataDir, "img", filename))
 	if err != nil {
-		log.Printf("Error creating image file: %s", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
 	defer file.Close()
 
 	log.Printf("Writing image data to file %s", file.Name())
-	file.Write(imageDataBytes)
+	_, err = file.Write(imageDataBytes)
+	if err != nil {
+		return err
+	}
 
 	// Save the image data
-	c.CharacterImage = filename
-	cd.UpdateCardData()
-	cd.SaveCardMap()
-
-	// Send an ok response
-	w.WriteHeader(http.StatusOK)
+	return cd.SetCardCharacterImage(id, filename)
 }
 
-func (cd *CardData) CardNewHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) CardNewHandler(w http.ResponseWriter, r *http.Request) error {
 	m := Meaning{
 		Meaning:        "Placeholder meaning",
 		Primary:        true,
@@ -344,78 +388,69 @@ func (cd *CardData) CardNewHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := Card{
-		ID:       cd.GetNewCardId(),
 		Meanings: []Meaning{m},
 		Readings: []Reading{r1, r2},
 	}
 
-	cd.Cards[c.ID] = &c
-	cd.UpdateCardData()
-	cd.SaveCardMap()
+	id, err := cd.AddNewCard(&c)
+	if err != nil {
+		return err
+	}
 
-	http.Redirect(w, r, fmt.Sprintf("/card/%d", c.ID), http.StatusFound)
+	http.Redirect(w, r, fmt.Sprintf("/card/%d", id), http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) CardDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardDeleteHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	log.Printf("Deleting card %d", id)
 
 	// Delete the card
-	cd.DeleteCard(id)
-	cd.UpdateCardData()
-	cd.SaveCardMap()
+	err = cd.RemoveCard(id)
+	if err != nil {
+		return err
+	}
 
 	http.Redirect(w, r, "/", http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) CardTagSuspendedHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardTagSuspendedHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	log.Printf("Tagging card %d as suspended", id)
 
-	c := cd.GetCard(id)
-	c.TagSuspended()
-	cd.UpdateCardData()
-	cd.RemoveUpNextCard(id) // Remove the card from the queue if it's there
-	cd.SaveCardMap()
+	err = cd.SuspendCard(id)
+	if err != nil {
+		return err
+	}
 
 	// Redirect to the card page
 	http.Redirect(w, r, fmt.Sprintf("/card/%d", id), http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) CardAddToQueueHandler(w http.ResponseWriter, r *http.Request) {
-	// Get card ID from URL
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) CardAddToQueueHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	log.Printf("Adding card %d to queue", id)
 
-	// Check that the card is available or unavailable
-	c := cd.GetCard(id)
-	if !c.IsQueueable() {
-		log.Printf("Card %d is not queuable", id)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	// Add the card to the queue. Only available or unavailable cards can be queued.
+	err = cd.QueueCard(id)
+	if err != nil {
+		return err
 	}
 
-	// Add the card to the queue
-	c.SetQueuedToLearn(cd)
-	cd.UpdateCardData()
-	cd.SaveCardMap()
-
 	// Redirect to the card page
 	http.Redirect(w, r, fmt.Sprintf("/card/%d", id), http.StatusFound)
+	return nil
 }
 
 type CardOverviewData struct {
@@ -451,9 +486,10 @@ func getOverviewLearningStage(cards []*Card, ls LearningStage) CardOverviewData
 	return o
 }
 
-func (cd *CardData) OverviewByLearningStageHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByLearningStageHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 
 	codl = append(codl, getOverviewLearningStage(cl, Unavailable))
 	codl = append(codl, getOverviewLearningStage(cl, Available))
@@ -463,12 +499,13 @@ func (cd *CardData) OverviewByLearningStageHandler(w http.ResponseWriter, r *htt
 	codl = append(codl, getOverviewLearningStage(cl, Learned))
 	codl = append(codl, getOverviewLearningStage(cl, Burned))
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) OverviewByLevelHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByLevelHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 
 	for i := 1; i <= 60; i++ {
 		cs := filterCardsByLevel(cl, i)
@@ -478,55 +515,56 @@ func (cd *CardData) OverviewByLevelHandler(w http.ResponseWriter, r *http.Reques
 		codl = append(codl, o)
 	}
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) OverviewByDueHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByDueHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
+	now := s.Now()
+
+	// The cards due between start and end that aren't suspended, soonest first
+	due := func(start time.Time, end time.Time) ([]*Card, error) {
+		cs, err := filterCardsByDueBetween(cl, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return sortCardsByDue(filterOutCardsByTag(cs, "suspended"))
+	}
 
 	// Due now
-	cs := filterCardsByDueBefore(cl, time.Now())
+	cs, err := due(time.Time{}, now)
+	if err != nil {
+		return err
+	}
 	cs = filterOutCardsByLearningStage(cs, UpNext)
-	cs = filterOutCardsByTag(cs, "suspended")
-	cs = sortCardsByDue(cs)
-	o := NewCardOverviewData("Due now", cs, 0, false)
-	codl = append(codl, o)
-
-	// Due in the next 24 hours
-	cs = filterCardsByDueBetween(cl, time.Now(), time.Now().Add(24*time.Hour))
-	cs = filterOutCardsByTag(cs, "suspended")
-	cs = sortCardsByDue(cs)
-	o = NewCardOverviewData("Due in the next 24 hours", cs, 0, false)
-	codl = append(codl, o)
-
-	// Due in the next 7 days
-	cs = filterCardsByDueBetween(cl, time.Now().Add(24*time.Hour), time.Now().Add(7*24*time.Hour))
-	cs = filterOutCardsByTag(cs, "suspended")
-	cs = sortCardsByDue(cs)
-	o = NewCardOverviewData("Due in the next week", cs, 0, false)
-	codl = append(codl, o)
-
-	// Due in the next 30 days
-	cs = filterCardsByDueBetween(cl, time.Now().Add(7*24*time.Hour), time.Now().Add(30*24*time.Hour))
-	cs = filterOutCardsByTag(cs, "suspended")
-	cs = sortCardsByDue(cs)
-	o = NewCardOverviewData("Due in the next month", cs, 0, false)
-	codl = append(codl, o)
+	codl = append(codl, NewCardOverviewData("Due now", cs, 0, false))
 
-	// Due in the next 365 days
-	cs = filterCardsByDueBetween(cl, time.Now().Add(30*24*time.Hour), time.Now().Add(365*24*time.Hour))
-	cs = filterOutCardsByTag(cs, "suspended")
-	cs = sortCardsByDue(cs)
-	o = NewCardOverviewData("Due in the next year", cs, 0, false)
-	codl = append(codl, o)
+	for _, d := range []struct {
+		title string
+		start time.Duration
+		end   time.Duration
+	}{
+		{"Due in the next 24 hours", 0, 24 * time.Hour},
+		{"Due in the next week", 24 * time.Hour, 7 * 24 * time.Hour},
+		{"Due in the next month", 7 * 24 * time.Hour, 30 * 24 * time.Hour},
+		{"Due in the next year", 30 * 24 * time.Hour, 365 * 24 * time.Hour},
+	} {
+		cs, err := due(now.Add(d.start), now.Add(d.end))
+		if err != nil {
+			return err
+		}
+		codl = append(codl, NewCardOverviewData(d.title, cs, 0, false))
+	}
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) OverviewByTypeHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByTypeHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 
 	cs := filterCardsByType(cl, "radical")
 	cs = sortCardsById(cs)
@@ -552,14 +590,15 @@ func (cd *CardData) OverviewByTypeHandler(w http.ResponseWriter, r *http.Request
 	o = NewCardOverviewData("Grammar", cs, lc, true)
 	codl = append(codl, o)
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) OverviewByPartsOfSpeechHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByPartsOfSpeechHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 
-	partsOfSpeech := cd.GetPartsOfSpeech()
+	partsOfSpeech := s.GetPartsOfSpeech()
 
 	for _, pos := range partsOfSpeech {
 		cs := filterCardsByPartsOfSpeech(cl, pos)
@@ -569,12 +608,13 @@ func (cd *CardData) OverviewByPartsOfSpeechHandler(w http.ResponseWriter, r *htt
 		codl = append(codl, o)
 	}
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) OverviewByReviewPerformanceHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByReviewPerformanceHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cardList := cd.ToList()
+	cardList := s.ToList()
 	cl := append(filterCardsByLearningStage(cardList, Learning), filterCardsByLearningStage(cardList, Learned)...)
 	cl = append(cl, filterCardsByLearningStage(cardList, Burned)...)
 
@@ -598,14 +638,21 @@ func (cd *CardData) OverviewByReviewPerformanceHandler(w http.ResponseWriter, r
 	o = NewCardOverviewData("95% - 100%", cs, 0, false)
 	codl = append(codl, o)
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
+}
+
+// Cards that keep being forgotten, with suggestions for making them stick
+func (cd *CardData) OverviewLeechesHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	return s.doTemplate(w, r, "leeches.html", s.GetLeeches())
 }
 
-func (cd *CardData) OverviewByTagHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewByTagHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 
-	tags := cd.GetTags()
+	tags := s.GetTags()
 
 	for _, tag := range tags {
 		cs := filterCardsByTag(cl, tag)
@@ -615,26 +662,25 @@ func (cd *CardData) OverviewByTagHandler(w http.ResponseWriter, r *http.Request)
 		codl = append(codl, o)
 	}
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) OverviewSimulateHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewSimulateHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	vars := mux.Vars(r)
 	correctRate := vars["correctRate"]
 	correctRateFloat, err := strconv.ParseFloat(correctRate, 64)
 	if err != nil {
-		log.Println(err)
-		return
+		return httpError(http.StatusBadRequest, err)
 	}
 	newCardsPerDay := vars["newCardsPerDay"]
 	newCardsPerDayInt, err := strconv.Atoi(newCardsPerDay)
 	if err != nil {
-		log.Println(err)
-		return
+		return httpError(http.StatusBadRequest, err)
 	}
 
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 	cl = filterCardsByLearned(cl)
 
 	// Create a copy of the card list
@@ -646,8 +692,8 @@ func (cd *CardData) OverviewSimulateHandler(w http.ResponseWriter, r *http.Reque
 		cl[i] = &v
 	}
 
-	// Begin with all cards due now
-	t := time.Now()
+	// Begin with all cards due now, and move the clock on a day at a time
+	clock := NewFixedClock(s.Now())
 
 	// Simulate the next 60 days
 	newCardCounter := 0
@@ -676,20 +722,24 @@ func (cd *CardData) OverviewSimulateHandler(w http.ResponseWriter, r *http.Reque
 		// Update all learning stages
 		for _, c := range cl {
 			// TODO: This is a hack to get the learning stage to update. I don't really want to use the real card data here.
-			c.UpdateLearningStage(cd)
+			c.UpdateLearningStage(s)
 		}
 
 		// Remove burned cards
 		cl = filterOutCardsByLearningStage(cl, Burned)
 		// Get the cards due today
-		cs := filterCardsByDueBefore(cl, t)
+		cs, err := filterCardsByDueBefore(cl, clock.Now())
+		if err != nil {
+			return err
+		}
 
 		// Fake review the cards
+		scheduler := s.GetScheduler()
 		for _, c := range cs {
 			if rand.Float64() < correctRateFloat {
-				c.ProcessCorrectAnswer()
+				scheduler.ProcessAnswer(c, Good, clock.Now())
 			} else {
-				c.ProcessIncorrectAnswer()
+				scheduler.ProcessAnswer(c, Again, clock.Now())
 			}
 		}
 
@@ -700,27 +750,8 @@ func (cd *CardData) OverviewSimulateHandler(w http.ResponseWriter, r *http.Reque
 			ShowLearnedCount: false,
 		})
 
-		// The calculated next review date is based on time.now(), so we need to fake it into the future.
-		// Get the time difference between now and the next review date and add on the t time., but if the time is in the past, don't change it, because it is an upnext learning card.
-		for _, c := range cs {
-			if c.NextReviewDate == "" {
-				// Burned cards don't have a next review date
-				continue
-			}
-			t1, err := time.Parse(time.RFC3339, c.NextReviewDate)
-			if err != nil {
-				panic(err)
-			}
-			t2 := time.Now()
-			if t1.Before(t2) {
-				continue
-			}
-			t3 := t1.Sub(t2)
-			c.NextReviewDate = t.Add(t3).Format(time.RFC3339)
-		}
-
 		// Move to the next day
-		t = t.Add(24 * time.Hour)
+		clock.Advance(24 * time.Hour)
 	}
 
 	pageData := struct {
@@ -733,12 +764,13 @@ func (cd *CardData) OverviewSimulateHandler(w http.ResponseWriter, r *http.Reque
 		codl,
 	}
 
-	cd.doTemplate(w, r, "simulation.html", pageData)
+	return s.doTemplate(w, r, "simulation.html", pageData)
 }
 
-func (cd *CardData) OverviewDebugHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) OverviewDebugHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	codl := []CardOverviewData{}
-	cl := cd.ToList()
+	cl := s.ToList()
 
 	cs := filterCardsByMissingCharacters(cl)
 	cs = filterCardsByMissingCharacterImage(cs)
@@ -746,71 +778,36 @@ func (cd *CardData) OverviewDebugHandler(w http.ResponseWriter, r *http.Request)
 	o := NewCardOverviewData("Missing characters", cs, 0, false)
 	codl = append(codl, o)
 
-	cd.doTemplate(w, r, "cardoverview.html", codl)
+	return s.doTemplate(w, r, "cardoverview.html", codl)
 }
 
-func (cd *CardData) TextAnalysisHandler(w http.ResponseWriter, r *http.Request) {
-	// Read all the files in the text analysis directory
-	files, err := ioutil.ReadDir("data/text_analysis")
+func (cd *CardData) TextAnalysisHandler(w http.ResponseWriter, r *http.Request) error {
+	taList, err := cd.ListTextAnalyses()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	var taList []TextAnalysis
-	for _, f := range files {
-		// Get the json data for the id
-		filepath := filepath.Join(cd.DataDir, "text_analysis", f.Name())
-		file, err := os.Open(filepath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer file.Close()
-
-		// Decode the json data
-		var ta TextAnalysis
-		decoder := json.NewDecoder(file)
-		err = decoder.Decode(&ta)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		taList = append(taList, ta)
-	}
-
-	// Sort the list by Name
-	sort.Slice(taList, func(i, j int) bool {
-		return taList[i].Name < taList[j].Name
-	})
-
 	pageData := struct {
 		TextAnalysisList []TextAnalysis
 	}{
 		TextAnalysisList: taList,
 	}
 
-	cd.doTemplate(w, r, "textanalysisoverview.html", pageData)
+	return cd.doTemplate(w, r, "textanalysisoverview.html", pageData)
 }
 
-func (cd *CardData) TextAnalysisIdHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) TextAnalysisIdHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	vars := mux.Vars(r)
-	id := vars["id"]
 
-	// Get the json data for the id
-	filepath := filepath.Join(cd.DataDir, "text_analysis", id+".json")
-	f, err := os.Open(filepath)
+	ta, err := s.GetTextAnalysis(vars["id"])
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer f.Close()
-
-	// Decode the json data
-	var ta TextAnalysis
-	decoder := json.NewDecoder(f)
-	err = decoder.Decode(&ta)
+	err = ta.Analyse(s)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	ta.Analyse(cd)
 
 	// Replace newlines with <br>
 	htmlText := strings.Replace(ta.Text, "\r\n", "<br>", -1)
@@ -825,14 +822,14 @@ func (cd *CardData) TextAnalysisIdHandler(w http.ResponseWriter, r *http.Request
 		HTMLSafeText: template.HTML(htmlText),
 	}
 
-	cd.doTemplate(w, r, "textanalysis.html", pageData)
+	return s.doTemplate(w, r, "textanalysis.html", pageData)
 }
 
-func (cd *CardData) TextAnalysisNewHandler(w http.ResponseWriter, r *http.Request) {
-	cd.doTemplate(w, r, "textanalysisnew.html", nil)
+func (cd *CardData) TextAnalysisNewHandler(w http.ResponseWriter, r *http.Request) error {
+	return cd.doTemplate(w, r, "textanalysisnew.html", nil)
 }
 
-func (cd *CardData) TextAnalysisNewSubmitHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) TextAnalysisNewSubmitHandler(w http.ResponseWriter, r *http.Request) error {
 	// Get the text from the form
 	name := r.FormValue("name")
 	text := r.FormValue("text")
@@ -840,38 +837,33 @@ func (cd *CardData) TextAnalysisNewSubmitHandler(w http.ResponseWriter, r *http.
 	log.Printf("New text analysis: %s", name)
 	log.Printf("Text: %s", text)
 
-	// Create a new text analysis
-	ta := TextAnalysis{
-		ID:   uuid.New().String(),
-		Name: name,
-		Text: text,
+	ta, err := cd.NewTextAnalysis(name, text)
+	if err != nil {
+		return err
 	}
 
-	// Save the text analysis
-	filepath := filepath.Join(cd.DataDir, "text_analysis", ta.ID+".json")
-	ta.Save(filepath)
-
 	// Redirect to the text analysis page
 	http.Redirect(w, r, "/textanalysis/"+ta.ID, http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) TextAnalysisIdDeleteHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) TextAnalysisIdDeleteHandler(w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	// Delete the file
-	filepath := filepath.Join(cd.DataDir, "text_analysis", id+".json")
-	err := os.Remove(filepath)
+	err := cd.DeleteTextAnalysis(id)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// Redirect to the text analysis overview page
 	http.Redirect(w, r, "/textanalysis", http.StatusFound)
+	return nil
 }
 
 type ScheduleData struct {
 	Schedule []ScheduleEntry
+	Vacation *VacationPeriod // The vacation you're on, if any
 }
 
 type ScheduleEntry struct {
@@ -879,13 +871,38 @@ type ScheduleEntry struct {
 	Count int
 }
 
-func (cd *CardData) ScheduleHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) ScheduleHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	schedule, err := s.GetScheduleData()
+	if err != nil {
+		return err
+	}
 	pageData := ScheduleData{}
-	pageData.Schedule = cd.GetScheduleData()
-	cd.doTemplate(w, r, "schedule.html", pageData)
+	pageData.Schedule = schedule
+	pageData.Vacation = s.CurrentVacation()
+	return s.doTemplate(w, r, "schedule.html", pageData)
+}
+
+func (cd *CardData) VacationStartHandler(w http.ResponseWriter, r *http.Request) error {
+	err := cd.StartVacation()
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/schedule", http.StatusFound)
+	return nil
+}
+
+func (cd *CardData) VacationEndHandler(w http.ResponseWriter, r *http.Request) error {
+	_, err := cd.EndVacation()
+	if err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/schedule", http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) SearchHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) SearchHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	var pageData struct {
 		SearchTerm    string
 		SearchResults []*Card
@@ -894,14 +911,14 @@ func (cd *CardData) SearchHandler(w http.ResponseWriter, r *http.Request) {
 	values := r.URL.Query()
 	q := values.Get("q")
 
-	searchResults := cd.Search(q)
+	searchResults := s.Search(q)
 
 	pageData.SearchTerm = q
 	pageData.SearchResults = searchResults
 
 	log.Printf("Search for %s returned %d results", q, len(searchResults))
 
-	cd.doTemplate(w, r, "search.html", pageData)
+	return s.doTemplate(w, r, "search.html", pageData)
 }
 
 type DictionarySearchData struct {
@@ -910,23 +927,28 @@ type DictionarySearchData struct {
 	DictSearchResults []DictionaryEntry
 }
 
-func (cd *CardData) DictionarySearchHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) DictionarySearchHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	// Get search query "q"
 	values := r.URL.Query()
 	q := values.Get("q")
 
-	searchResults := SearchDictionary(cd, q)
+	searchResults, err := SearchDictionary(s, q)
+	if err != nil {
+		return err
+	}
 
 	log.Printf("Dictionary search for %s returned %d results", q, len(searchResults.DictSearchResults))
 
-	cd.doTemplate(w, r, "dictionarysearch.html", searchResults)
+	return s.doTemplate(w, r, "dictionarysearch.html", searchResults)
 }
 
 type DictionaryEntriesData struct {
 	DictEntries []DictionaryEntry
 }
 
-func (cd *CardData) DictionaryEntriesHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) DictionaryEntriesHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
 	// Get the ids from the URL query
 	// e.g. /dictionaryentries?ids=1,2,3
 	values := r.URL.Query()
@@ -938,8 +960,7 @@ func (cd *CardData) DictionaryEntriesHandler(w http.ResponseWriter, r *http.Requ
 	for _, id := range idSlice {
 		idInt, err := strconv.Atoi(id)
 		if err != nil {
-			log.Printf("Error converting id to int: %s", err)
-			return
+			return httpError(http.StatusBadRequest, err)
 		}
 		idIntSlice = append(idIntSlice, idInt)
 	}
@@ -947,93 +968,43 @@ func (cd *CardData) DictionaryEntriesHandler(w http.ResponseWriter, r *http.Requ
 	// Get the dictionary entries
 	var dictEntries []DictionaryEntry
 	for _, id := range idIntSlice {
-		entry := cd.DictionaryMap[id]
-		dictEntries = append(dictEntries, convertJmdictEntryToDictionaryEntry(cd, *entry))
+		entry, ok := s.DictionaryMap[id]
+		if !ok {
+			return fmt.Errorf("dictionary entry %d: %w", id, ErrDictionaryEntryNotFound)
+		}
+		dictEntries = append(dictEntries, convertJmdictEntryToDictionaryEntry(s, *entry))
 	}
 
 	pageData := DictionaryEntriesData{
 		DictEntries: dictEntries,
 	}
 
-	cd.doTemplate(w, r, "dictionaryentries.html", pageData)
+	return s.doTemplate(w, r, "dictionaryentries.html", pageData)
 }
 
-func (cd *CardData) AddDictionaryAsCardHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (cd *CardData) AddDictionaryAsCardHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := routeID(r)
 	if err != nil {
-		log.Printf("Error converting id to int: %s", err)
-		return
-	}
-
-	entry := cd.DictionaryMap[id]
-	dictEntry := convertJmdictEntryToDictionaryEntry(cd, *entry)
-	mainCharacter := dictEntry.Expressions[0]
-	otherCharacters := dictEntry.Expressions[1:]
-
-	var meanings []Meaning
-	var partsOfSpeech []string
-	for _, m := range dictEntry.Definitions {
-		for _, d := range m.Definitions {
-			meanings = append(meanings, Meaning{
-				Meaning:        d,
-				Primary:        false,
-				AcceptedAnswer: false,
-			})
-		}
-		for _, m := range m.PartsOfSpeech {
-			if !containsString(partsOfSpeech, m) {
-				partsOfSpeech = append(partsOfSpeech, m)
-			}
-		}
+		return err
 	}
 
-	var readings []Reading
-	for _, m := range dictEntry.Readings {
-		readings = append(readings, Reading{
-			Reading:        m,
-			Primary:        false,
-			AcceptedAnswer: false,
-		})
+	c, err := cd.Snapshot().NewCardFromDictionary(id)
+	if err != nil {
+		return err
 	}
 
-	kanjis := kana.ExtractKanji(mainCharacter)
-	var componentIds []int
-	var defaultMeaningMneumonic string
-	for _, k := range kanjis {
-		kanjiCard := cd.FindKanji(k)
-		if kanjiCard != nil {
-			componentIds = append(componentIds, kanjiCard.ID)
-			defaultMeaningMneumonic += "<kanji>" + kanjiCard.Meanings[0].Meaning + "</kanji> "
-		}
+	newId, err := cd.AddNewCard(c)
+	if err != nil {
+		return err
 	}
 
-	// Create a new card
-	c := Card{
-		ID:                          cd.GetNewCardId(),
-		Object:                      "vocabulary", // New cards from dictionary are always vocabulary
-		Level:                       0,            // So they don't appear as a wanikani level card
-		Characters:                  mainCharacter,
-		CharactersAlternateWritings: otherCharacters,
-		Meanings:                    meanings,
-		Readings:                    readings,
-		PartsOfSpeech:               partsOfSpeech,
-		ComponentSubjectIDs:         componentIds,
-		Tags:                        []string{"TODO", "added_from_dictionary"},
-		MeaningMnemonic:             defaultMeaningMneumonic,
-		ReadingMnemonic:             "This is a jukugo word, which usually means on'yomi readings from the kanji. If you know the readings of your kanji you'll know how to read this as well.",
-	}
-
-	cd.AddCard(&c)
-	cd.UpdateCardData()
-	cd.SaveCardMap()
-
 	// Redirect to the card page
-	http.Redirect(w, r, fmt.Sprintf("/card/%d", c.ID), http.StatusFound)
+	http.Redirect(w, r, fmt.Sprintf("/card/%d", newId), http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) OtherHandler(w http.ResponseWriter, r *http.Request) {
-	cd.doTemplate(w, r, "other.html", nil)
+func (cd *CardData) OtherHandler(w http.ResponseWriter, r *http.Request) error {
+	return cd.doTemplate(w, r, "other.html", nil)
 }
 
 type KanjiFrequencyData struct {
@@ -1041,71 +1012,142 @@ type KanjiFrequencyData struct {
 	TotalPercent int
 }
 
-func (cd *CardData) KanjiFrequencyHandler(w http.ResponseWriter, r *http.Request) {
-	kf := cd.GetKanjiFrequencyData()
+func (cd *CardData) KanjiFrequencyHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	kf, err := s.GetKanjiFrequencyData()
+	if err != nil {
+		return err
+	}
 	pageData := struct {
 		KanjiFrequencyData []KanjiFrequencyData
 	}{
 		KanjiFrequencyData: kf,
 	}
-	cd.doTemplate(w, r, "kanjifrequency.html", pageData)
+	return s.doTemplate(w, r, "kanjifrequency.html", pageData)
 }
 
-func (cd *CardData) HistoricalStatsHandler(w http.ResponseWriter, r *http.Request) {
-	cd.doTemplate(w, r, "historicalstats.html", cd.GetHistoricalData())
+func (cd *CardData) HistoricalStatsHandler(w http.ResponseWriter, r *http.Request) error {
+	s := cd.Snapshot()
+	data, err := s.GetHistoricalData()
+	if err != nil {
+		return err
+	}
+	return s.doTemplate(w, r, "historicalstats.html", data)
 }
 
-func (cd *CardData) DebugAddToUpNextQueueHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) BackupsHandler(w http.ResponseWriter, r *http.Request) error {
+	backups, err := cd.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	pageData := struct {
+		Backups   []Backup
+		Retention RetentionPolicy
+	}{
+		Backups:   backups,
+		Retention: cd.BackupRetention,
+	}
+
+	return cd.doTemplate(w, r, "backups.html", pageData)
+}
+
+func (cd *CardData) BackupHandler(w http.ResponseWriter, r *http.Request) error {
+	vars := mux.Vars(r)
+	diff, err := cd.DiffBackup(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	return cd.doTemplate(w, r, "backup.html", diff)
+}
+
+func (cd *CardData) BackupRestoreHandler(w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
-	cardId, err := strconv.Atoi(vars["id"])
+	err := cd.RestoreBackup(vars["name"])
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, "/backups", http.StatusFound)
+	return nil
+}
+
+func (cd *CardData) DebugAddToUpNextQueueHandler(w http.ResponseWriter, r *http.Request) error {
+	cardId, err := routeID(r)
 	if err != nil {
-		log.Printf("Error converting id to int: %s", err)
-		return
+		return err
 	}
 
-	c := cd.GetCard(cardId)
-	cd.UpNext = append(cd.UpNext, c)
+	err = cd.AddToUpNextQueue(cardId)
+	if err != nil {
+		return err
+	}
 
 	// Redirect to the card page
 	http.Redirect(w, r, fmt.Sprintf("/card/%d", cardId), http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) SrsHandler(w http.ResponseWriter, r *http.Request) {
-	srsData := cd.GetNextSrsCard()
+func (cd *CardData) SrsHandler(w http.ResponseWriter, r *http.Request) error {
+	srsData, s := cd.NextSrsCard()
+	canUndo := cd.CanUndo(clientID(r))
 	if srsData.Card == nil {
-		cd.doTemplate(w, r, "srsnomorecards.html", cd.GetNextScheduledHour())
-		return
+		noMoreCards, err := s.GetNextScheduledHour()
+		if err != nil {
+			return err
+		}
+		noMoreCards.CanUndo = canUndo
+		noMoreCards.HeldBack = srsData.HeldBack
+		noMoreCards.NextDayStart = s.DayBoundary.NextDayStart(s.Now()).Format("15:04")
+		return s.doTemplate(w, r, "srsnomorecards.html", noMoreCards)
 	}
+	srsData.CanUndo = canUndo
 
 	switch srsData.Card.Object {
 	case "grammar":
-		cd.doTemplate(w, r, "srsgrammar.html", srsData)
+		return s.doTemplate(w, r, "srsgrammar.html", srsData)
 	case "radical":
-		cd.doTemplate(w, r, "srsradical.html", srsData)
+		return s.doTemplate(w, r, "srsradical.html", srsData)
 	default:
-		cd.doTemplate(w, r, "srs.html", srsData)
+		return s.doTemplate(w, r, "srs.html", srsData)
 	}
 }
 
 type SrsNoMoreCards struct {
-	NextHour  string
-	NumberDue int
+	NextHour     string
+	NumberDue    int
+	CanUndo      bool
+	OnVacation   bool
+	HeldBack     int    // Reviews due, but over the daily limit
+	NextDayStart string // HH:MM the next study day starts, when the limits reset
 }
 
-func (cd *CardData) GetNextScheduledHour() SrsNoMoreCards {
+func (cd *CardData) GetNextScheduledHour() (SrsNoMoreCards, error) {
 	// Go through each hour until you find one that has cards due
 
-	cards := cd.ToList()
-	t1 := time.Now().Truncate(time.Hour)
+	if cd.CurrentVacation() != nil {
+		return SrsNoMoreCards{OnVacation: true}, nil
+	}
+	cards := expandFacets(cd.ToList())
+	t1 := cd.Now().Truncate(time.Hour)
 	t2 := t1.Add(time.Hour)
+
+	// Without this, the search below would never end
+	cs, err := filterCardsByDueBetween(cards, t1, t1.AddDate(100, 0, 0))
+	if err != nil || len(cs) == 0 {
+		return SrsNoMoreCards{}, err
+	}
+
 	for {
-		cs := filterCardsByDueBetween(cards, t1, t2)
+		// Every date parsed above, so this can't fail
+		cs, _ := filterCardsByDueBetween(cards, t1, t2)
 		if len(cs) > 0 {
 			s := SrsNoMoreCards{
 				NextHour:  t2.Format("15:04"),
 				NumberDue: len(cs),
 			}
-			return s
+			return s, nil
 		}
 
 		t1 = t1.Add(time.Hour)
@@ -1113,72 +1155,114 @@ func (cd *CardData) GetNextScheduledHour() SrsNoMoreCards {
 	}
 }
 
-func (cd *CardData) SrsCorrectHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	cardId, err := strconv.Atoi(vars["id"])
+func (cd *CardData) SrsCorrectHandler(w http.ResponseWriter, r *http.Request) error {
+	cardId, err := routeID(r)
 	if err != nil {
-		log.Printf("Error converting id to int: %s", err)
-		return
+		return err
 	}
 
-	log.Printf("Correct answer for card %d", cardId)
-	c := cd.GetCard(cardId)
-	prevState := c.GetLearningStageString()
+	return cd.answerSrsCard(w, r, cardId, Good)
+}
+
+func (cd *CardData) SrsIncorrectHandler(w http.ResponseWriter, r *http.Request) error {
+	cardId, err := routeID(r)
+	if err != nil {
+		return err
+	}
+
+	return cd.answerSrsCard(w, r, cardId, Again)
+}
 
-	c.CorrectAnswer()
+func (cd *CardData) SrsAnswerHandler(w http.ResponseWriter, r *http.Request) error {
+	cardId, err := routeID(r)
+	if err != nil {
+		return err
+	}
+	g, err := ParseGrade(mux.Vars(r)["grade"])
+	if err != nil {
+		return httpError(http.StatusBadRequest, err)
+	}
+
+	return cd.answerSrsCard(w, r, cardId, g)
+}
 
-	cd.UpdateCardData()
-	cd.SaveCardMap()
+// The SRS pages send the facet being answered as ?facet=, meaning if it is missing
+func (cd *CardData) answerSrsCard(w http.ResponseWriter, r *http.Request, cardId int, g Grade) error {
+	f, err := ParseFacet(r.URL.Query().Get("facet"))
+	if err != nil {
+		return httpError(http.StatusBadRequest, err)
+	}
 
-	currentState := c.GetLearningStageString()
-	if currentState != prevState {
-		log.Printf("Card %d changed from %s to %s", cardId, prevState, currentState)
-		s := cd.GetNextSrsCard()
+	log.Printf("Answer %s for the %s of card %d", g, f, cardId)
+	result, err := cd.AnswerCardInSession(clientID(r), cardId, f, g, getResponseTime(r))
+	if err != nil {
+		return err
+	}
+	c := result.Card
+
+	// Celebrate facets that have moved up a stage.
+	// Failed facets only ever move down, so they go straight back to the SRS page.
+	prevState := LearningStageToString(result.PreviousStage)
+	currentState := LearningStageToString(result.NewStage)
+	if g != Again && currentState != prevState {
+		log.Printf("The %s of card %d changed from %s to %s", f, cardId, prevState, currentState)
+		// Only the counts are needed, so the snapshot's queue rotation doesn't matter
+		s := cd.Snapshot().GetNextSrsCard()
 		pageData := struct {
 			Card          *Card
+			Facet         Facet
+			Stage         string
 			DueCount      int
 			LearningCount int
 		}{
 			Card:          c,
+			Facet:         f,
+			Stage:         currentState,
 			DueCount:      s.DueCount,
 			LearningCount: s.LearningCount,
 		}
 
-		cd.doTemplate(w, r, "congratulationssrs.html", pageData)
-		return
+		return cd.doTemplate(w, r, "congratulationssrs.html", pageData)
 	}
 
 	http.Redirect(w, r, "/srs", http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) SrsIncorrectHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	cardId, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		log.Printf("Error converting id to int: %s", err)
-		return
+// The SRS pages send the number of milliseconds the card was on screen as ?responsetime=
+func getResponseTime(r *http.Request) int {
+	ms, err := strconv.Atoi(r.URL.Query().Get("responsetime"))
+	if err != nil || ms < 0 {
+		return 0
 	}
+	return ms
+}
 
-	log.Printf("Incorrect answer for card %d", cardId)
-	c := cd.GetCard(cardId)
-	c.IncorrectAnswer()
-
-	cd.UpdateCardData()
-	cd.SaveCardMap()
+// Undo the last answer, and go back to reviewing
+func (cd *CardData) SrsUndoHandler(w http.ResponseWriter, r *http.Request) error {
+	result, err := cd.UndoAnswer(clientID(r))
+	if err != nil {
+		return err
+	}
+	log.Printf("Undid answer %s for the %s of card %d", result.Grade, result.Facet, result.Card.ID)
 
 	http.Redirect(w, r, "/srs", http.StatusFound)
+	return nil
 }
 
-func (cd *CardData) SrsAddUpNextCardsHandler(w http.ResponseWriter, r *http.Request) {
+func (cd *CardData) SrsAddUpNextCardsHandler(w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
 	n, err := strconv.Atoi(vars["n"])
 	if err != nil {
-		log.Printf("Error converting number to int: %s", err)
-		return
+		return httpError(http.StatusBadRequest, err)
 	}
 
 	log.Printf("Adding %d cards to up next", n)
-	cd.AddUpNextCards(n)
+	err = cd.QueueUpNextCards(n)
+	if err != nil {
+		return err
+	}
 
 	http.Redirect(w, r, "/srs", http.StatusFound)
+	return nil
 }
@@ -180,25 +180,25 @@ func checkCardListLenAndIds(t *testing.T, length int, codl []*Card, expectedIds
 func TestFilterDue(t *testing.T) {
 	cs := DueCardsSlice()
 
-	codl := filterCardsByDueBefore(cs, time.Now())
+	codl, _ := filterCardsByDueBefore(cs, time.Now())
 	checkCardListLenAndIds(t, 2, codl, []int{2, 3})
 
-	codl = filterCardsByDueBefore(cs, time.Now().Add(24*time.Hour))
+	codl, _ = filterCardsByDueBefore(cs, time.Now().Add(24*time.Hour))
 	checkCardListLenAndIds(t, 3, codl, []int{2, 3, 4})
 
-	codl = filterCardsByDueBefore(cs, time.Now().Add(24*7*time.Hour))
+	codl, _ = filterCardsByDueBefore(cs, time.Now().Add(24*7*time.Hour))
 	checkCardListLenAndIds(t, 4, codl, []int{2, 3, 4, 5})
 
-	codl = filterCardsByDueBefore(cs, time.Now().Add(24*30*time.Hour))
+	codl, _ = filterCardsByDueBefore(cs, time.Now().Add(24*30*time.Hour))
 	checkCardListLenAndIds(t, 5, codl, []int{2, 3, 4, 5, 6})
 
-	codl = filterCardsByDueBetween(cs, time.Now(), time.Now().Add(24*time.Hour))
+	codl, _ = filterCardsByDueBetween(cs, time.Now(), time.Now().Add(24*time.Hour))
 	checkCardListLenAndIds(t, 1, codl, []int{4})
 
-	codl = filterCardsByDueBetween(cs, time.Now().Add(24*time.Hour), time.Now().Add(24*7*time.Hour))
+	codl, _ = filterCardsByDueBetween(cs, time.Now().Add(24*time.Hour), time.Now().Add(24*7*time.Hour))
 	checkCardListLenAndIds(t, 1, codl, []int{5})
 
-	codl = filterCardsByDueBetween(cs, time.Now().Add(24*7*time.Hour), time.Now().Add(24*30*time.Hour))
+	codl, _ = filterCardsByDueBetween(cs, time.Now().Add(24*7*time.Hour), time.Now().Add(24*30*time.Hour))
 	checkCardListLenAndIds(t, 1, codl, []int{6})
 }
 
@@ -0,0 +1,199 @@
+package cards
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The journal makes each change durable before the full cards file is rewritten.
+// Every Update appends the cards it changed to the journal and fsyncs it,
+// then saves the cards file atomically and removes the journal.
+// If the process dies before the cards file is saved, LoadCardJson replays the journal.
+//
+// Each line of the journal is one JournalRecord, so a change that touches several cards
+// (e.g. deleting a card removes it from its amalgamations) is replayed all or nothing.
+
+const (
+	JournalPut    = "put"
+	JournalDelete = "delete"
+)
+
+type JournalRecord struct {
+	Timestamp string         `json:"timestamp"` // RFC3339 date string
+	Changes   []JournalEntry `json:"changes"`
+}
+
+type JournalEntry struct {
+	Op   string          `json:"op"` // JournalPut or JournalDelete
+	ID   int             `json:"id"`
+	Card json.RawMessage `json:"card,omitempty"` // The card after the change. Only set for JournalPut.
+}
+
+func (cd *CardData) JournalFile() string {
+	if cd.ProfileDir != "" {
+		return filepath.Join(cd.ProfileDir, "cards.journal")
+	}
+	return cd.CardsFile + ".journal"
+}
+
+// JSON of every card, used to work out which cards have changed since the last save
+func (cd *CardData) cardImages() (map[int][]byte, error) {
+	images := make(map[int][]byte, len(cd.Cards))
+	for id, c := range cd.Cards {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		images[id] = b
+	}
+	return images, nil
+}
+
+// Journal the changes since the last save, save the cards file, then clear the journal.
+// Caller must hold the lock
+func (cd *CardData) commit() error {
+	images, err := cd.cardImages()
+	if err != nil {
+		return err
+	}
+
+	var changes []JournalEntry
+	for id, b := range images {
+		if !bytes.Equal(cd.savedImages[id], b) {
+			changes = append(changes, JournalEntry{Op: JournalPut, ID: id, Card: b})
+		}
+	}
+	for id := range cd.savedImages {
+		if _, ok := images[id]; !ok {
+			changes = append(changes, JournalEntry{Op: JournalDelete, ID: id})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	err = AppendJournal(cd.JournalFile(), JournalRecord{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Changes:   changes,
+	})
+	if err != nil {
+		return err
+	}
+
+	// If this fails, the change is safe in the journal and will be replayed on the next start up
+	return cd.saveAndClearJournal(images)
+}
+
+// Caller must hold the lock
+func (cd *CardData) saveAndClearJournal(images map[int][]byte) error {
+	err := cd.SaveCardMap()
+	if err != nil {
+		return err
+	}
+	cd.savedImages = images
+
+	// Replaying a journal over the cards it was saved to changes nothing, so this failing is harmless
+	err = os.Remove(cd.JournalFile())
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing journal: %s", err)
+	}
+	return nil
+}
+
+// Save the cards whether or not they have changed, so a save that failed earlier is retried.
+// Used on shutdown.
+func (cd *CardData) Flush() error {
+	if cd.readOnly {
+		return ErrReadOnly
+	}
+
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	images, err := cd.cardImages()
+	if err != nil {
+		return err
+	}
+	return cd.saveAndClearJournal(images)
+}
+
+func AppendJournal(path string, record JournalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// Apply the records in a journal to the cards. Returns the number of records replayed.
+// A missing journal is not an error. Replay stops at the first unreadable record,
+// which can only be a partly written record from a crash mid-append.
+func ReplayJournal(path string, cards map[int]*Card) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	replayed := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var record JournalRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			log.Printf("Ignoring unreadable journal record %d: %s", replayed+1, err)
+			break
+		}
+
+		// Decode every card before applying any, so a bad record changes nothing
+		puts := make(map[int]*Card)
+		for _, e := range record.Changes {
+			if e.Op != JournalPut {
+				continue
+			}
+			var c Card
+			err = json.Unmarshal(e.Card, &c)
+			if err != nil {
+				break
+			}
+			puts[e.ID] = &c
+		}
+		if err != nil {
+			log.Printf("Ignoring unreadable journal record %d: %s", replayed+1, err)
+			break
+		}
+
+		for _, e := range record.Changes {
+			switch e.Op {
+			case JournalPut:
+				cards[e.ID] = puts[e.ID]
+			case JournalDelete:
+				delete(cards, e.ID)
+			}
+		}
+		replayed++
+	}
+
+	return replayed, scanner.Err()
+}
@@ -0,0 +1,112 @@
+package cards
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Read the saved cards and progress back into cards
+func loadSavedCards(t *testing.T, cd *CardData) map[int]*Card {
+	cards, err := ReadCardsFile(cd.CardsFile)
+	if err != nil {
+		t.Fatalf("Error reading cards file: %s", err)
+	}
+	progress, err := ReadProgressFile(cd.ProgressFile())
+	if err != nil {
+		t.Fatalf("Error reading progress file: %s", err)
+	}
+	for id, p := range progress {
+		if c, ok := cards[id]; ok {
+			c.SetProgress(p)
+		}
+	}
+	return cards
+}
+
+func TestUpdateSavesAndClearsJournal(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+
+	_, err := cd.AnswerCard(1, MeaningFacet, Good, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+
+	cards := loadSavedCards(t, cd)
+	if cards[1].Interval != 96 {
+		t.Errorf("Expected saved interval 96, got %d", cards[1].Interval)
+	}
+	if _, err := os.Stat(cd.JournalFile()); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be removed after saving, got %v", err)
+	}
+
+	// No temporary files should be left behind
+	files, err := ioutil.ReadDir(cd.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Name() != "cards.json" && f.Name() != "progress.json" && f.Name() != "revlog.jsonl" {
+			t.Errorf("Unexpected file left in data dir: %s", f.Name())
+		}
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	cd := createStoreCardData(t, 3)
+	cd.BackupDir = filepath.Join(cd.DataDir, "backup")
+	err := cd.SaveCardMap()
+	if err != nil {
+		t.Fatalf("Error saving cards: %s", err)
+	}
+
+	// Changes that were journaled, but the process died before the cards file was saved
+	c := cd.Cards[1].Copy()
+	c.Interval = 500
+	b, _ := json.Marshal(c)
+	err = AppendJournal(cd.JournalFile(), JournalRecord{
+		Changes: []JournalEntry{
+			{Op: JournalPut, ID: 1, Card: b},
+			{Op: JournalDelete, ID: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error writing journal: %s", err)
+	}
+
+	// A record that was only partly written when the process died
+	f, err := os.OpenFile(cd.JournalFile(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"timestamp":"2023-01-01T00:00:00Z","changes":[{"op":"delete","id":3`)
+	f.Close()
+
+	loaded := CardData{
+		CardsFile: cd.CardsFile,
+		DataDir:   cd.DataDir,
+		BackupDir: cd.BackupDir,
+	}
+	loaded.LoadCardJson()
+
+	if loaded.Cards[1].Interval != 500 {
+		t.Errorf("Expected replayed interval 500, got %d", loaded.Cards[1].Interval)
+	}
+	if _, ok := loaded.Cards[2]; ok {
+		t.Errorf("Expected card 2 to be deleted by the journal")
+	}
+	if _, ok := loaded.Cards[3]; !ok {
+		t.Errorf("Expected card 3 to survive the partly written record")
+	}
+
+	// The replayed changes are saved and the journal is cleared
+	cards := loadSavedCards(t, cd)
+	if cards[1].Interval != 500 || len(cards) != 2 {
+		t.Errorf("Expected replayed changes to be saved, got %d cards", len(cards))
+	}
+	if _, err := os.Stat(cd.JournalFile()); !os.IsNotExist(err) {
+		t.Errorf("Expected the journal to be removed after replay, got %v", err)
+	}
+}
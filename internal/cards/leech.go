@@ -0,0 +1,197 @@
+package cards
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// A leech is a facet you keep forgetting, which takes up reviews without sticking.
+// Each lapse, an Again answer to a learned facet, is counted, and any other answer to it resets the count.
+// Once a facet lapses LeechThreshold times in a row, the card is tagged as a leech and the LeechAction is taken.
+
+type LeechAction string
+
+const (
+	LeechTag     LeechAction = "tag"     // Only tag the card
+	LeechSuspend LeechAction = "suspend" // Also suspend the card until it is fixed
+	LeechRelearn LeechAction = "relearn" // Also put the facet back on the up next queue, to be learned again from the start
+)
+
+var LeechActions = []LeechAction{LeechTag, LeechSuspend, LeechRelearn}
+
+const LeechTagName = "leech"
+
+func ParseLeechAction(s string) (LeechAction, error) {
+	for _, a := range LeechActions {
+		if s == string(a) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown leech action %q, expected one of %v", s, LeechActions)
+}
+
+// Count a lapse, or reset the count, after answering a card that was in prevStage
+func (c *Card) countLapse(prevStage LearningStage, g Grade) {
+	if prevStage != Learned && prevStage != Burned {
+		return
+	}
+	if g == Again {
+		c.Lapses++
+	} else {
+		c.Lapses = 0
+	}
+}
+
+func (c *Card) AddTag(tag string) {
+	if !containsString(c.Tags, tag) {
+		c.Tags = append(c.Tags, tag)
+	}
+}
+
+// Start a facet again from the up next stage, keeping its review counts
+func (c *Card) relearnFacet(f Facet) {
+	c.splitFacets()
+	r := c.Review(f)
+	r = FacetReview{
+		NextReviewDate:     time.Unix(0, 0).Format(time.RFC3339),
+		TotalTimesReviewed: r.TotalTimesReviewed,
+		TotalTimesCorrect:  r.TotalTimesCorrect,
+		LearningStage:      UpNext,
+	}
+	if f == ReadingFacet {
+		c.ReadingReview = &r
+		return
+	}
+	c.setMeaningReview(r)
+	// Up next is worked out from the queued flag when the learning stages are updated
+	c.QueuedToLearn = true
+}
+
+// Tag the card and take the leech action if the facet has lapsed too many times in a row.
+// Returns whether the facet is a leech.
+// Caller must hold the lock
+func (cd *CardData) handleLeech(c *Card, f Facet) bool {
+	st := cd.GetScheduler().Settings()
+	if c.Review(f).Lapses < st.LeechThreshold {
+		return false
+	}
+
+	log.Printf("The %s of card %d is a leech, with %d lapses in a row", f, c.ID, c.Review(f).Lapses)
+	c.AddTag(LeechTagName)
+	switch st.LeechAction {
+	case LeechSuspend:
+		c.AddTag("suspended")
+		cd.RemoveUpNextCard(c.ID)
+	case LeechRelearn:
+		c.relearnFacet(f)
+		cd.RemoveUpNextCard(c.ID)
+		cd.UpNext = append(cd.UpNext, c)
+	}
+	return true
+}
+
+// A leech and what might help to learn it
+type Leech struct {
+	Card        *Card
+	Facets      []Facet // The facets that are leeches
+	Lapses      int     // The most lapses in a row of any facet
+	Suggestions []string
+	Siblings    []*Card // Cards that may be getting confused with this one
+}
+
+// Every card that is tagged as a leech or has a facet that has lapsed too many times, most lapses first
+func (cd *CardData) GetLeeches() []Leech {
+	threshold := cd.GetScheduler().Settings().LeechThreshold
+	var leeches []Leech
+	for _, c := range sortCardsById(cd.ToList()) {
+		l := Leech{Card: c}
+		for _, f := range c.Facets() {
+			lapses := c.Review(f).Lapses
+			if lapses >= threshold {
+				l.Facets = append(l.Facets, f)
+			}
+			if lapses > l.Lapses {
+				l.Lapses = lapses
+			}
+		}
+		if len(l.Facets) == 0 {
+			if !containsString(c.Tags, LeechTagName) {
+				continue
+			}
+			// Tagged before, and relearned or answered since, so it isn't known which facet
+			l.Facets = c.Facets()
+		}
+		l.Siblings = cd.confusableSiblings(c)
+		l.Suggestions = leechSuggestions(c, l.Facets, l.Siblings)
+		leeches = append(leeches, l)
+	}
+	sort.SliceStable(leeches, func(i, j int) bool {
+		return leeches[i].Lapses > leeches[j].Lapses
+	})
+	return leeches
+}
+
+func leechSuggestions(c *Card, facets []Facet, siblings []*Card) []string {
+	var suggestions []string
+	for _, f := range facets {
+		switch {
+		case f == MeaningFacet && c.MeaningMnemonic == "":
+			suggestions = append(suggestions, "Add a meaning mnemonic")
+		case f == ReadingFacet && c.ReadingMnemonic == "":
+			suggestions = append(suggestions, "Add a reading mnemonic")
+		}
+		if f == MeaningFacet && len(c.UserSynonyms) == 0 {
+			suggestions = append(suggestions, "If you know it by another word, add that as a synonym")
+		}
+	}
+	if len(siblings) > 0 {
+		suggestions = append(suggestions, "Compare it with the similar cards below, and make the mnemonics tell them apart")
+	}
+	if containsString(c.Tags, "suspended") {
+		suggestions = append(suggestions, "Remove the suspended tag once it's fixed")
+	}
+	return suggestions
+}
+
+// Cards of the same type, already being reviewed, that share a component with c
+// and also share a reading or are being forgotten too
+func (cd *CardData) confusableSiblings(c *Card) []*Card {
+	var siblings []*Card
+	for _, o := range sortCardsById(cd.ToList()) {
+		if o.ID == c.ID || o.Object != c.Object || !o.isBeingReviewed() || !sharesComponent(c, o) {
+			continue
+		}
+		if sharesReading(c, o) || o.Lapses > 0 || (o.ReadingReview != nil && o.ReadingReview.Lapses > 0) {
+			siblings = append(siblings, o)
+		}
+	}
+	return siblings
+}
+
+func (c *Card) isBeingReviewed() bool {
+	return c.LearningStage == Learning || c.LearningStage == Learned || c.LearningStage == Burned
+}
+
+func sharesComponent(a *Card, b *Card) bool {
+	for _, id := range a.ComponentSubjectIDs {
+		for _, o := range b.ComponentSubjectIDs {
+			if id == o {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sharesReading(a *Card, b *Card) bool {
+	for _, r := range a.Readings {
+		for _, o := range b.Readings {
+			if r.AcceptedAnswer && o.AcceptedAnswer && normaliseReading(r.Reading) == normaliseReading(o.Reading) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package cards
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Card data whose card 1 lapses into a leech on its next Again
+func createLeechCardData(t *testing.T, action LeechAction) *CardData {
+	cd := createStoreCardData(t, 2)
+	cd.Scheduler = DoublingScheduler{SrsSettings: SrsSettings{LeechThreshold: 3, LeechAction: action}}
+	cd.Cards[1].Lapses = 2
+	return cd
+}
+
+func TestLapsesCount(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+
+	_, err := cd.AnswerCard(1, MeaningFacet, Again, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+	if cd.Cards[1].Lapses != 1 {
+		t.Errorf("Expected 1 lapse, got %d", cd.Cards[1].Lapses)
+	}
+
+	// Remembering a learned card starts the count again
+	cd.Cards[2].Lapses = 2
+	_, err = cd.AnswerCard(2, MeaningFacet, Hard, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+	if cd.Cards[2].Lapses != 0 {
+		t.Errorf("Expected lapses to be reset, got %d", cd.Cards[2].Lapses)
+	}
+}
+
+func TestLeechActions(t *testing.T) {
+	cd := createLeechCardData(t, LeechTag)
+	result, err := cd.AnswerCard(1, MeaningFacet, Again, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+	if !result.Leech || !containsString(cd.Cards[1].Tags, LeechTagName) || containsString(cd.Cards[1].Tags, "suspended") {
+		t.Errorf("Expected the card to be tagged as a leech, got %v", cd.Cards[1].Tags)
+	}
+
+	cd = createLeechCardData(t, LeechSuspend)
+	cd.Cards[1].Tags = []string{"kanji"}
+	cd.AnswerCard(1, MeaningFacet, Again, 0)
+	if tags := cd.Cards[1].Tags; len(tags) != 3 || !containsString(tags, "suspended") {
+		t.Errorf("Expected the card to be suspended and keep its tags, got %v", tags)
+	}
+
+	cd = createLeechCardData(t, LeechRelearn)
+	cd.AnswerCard(1, MeaningFacet, Again, 0)
+	c := cd.Cards[1]
+	if c.LearningStage != UpNext || c.Interval != 0 || c.Lapses != 0 {
+		t.Errorf("Expected the card to be relearned from up next, got stage %s interval %d lapses %d", c.LearningStageString, c.Interval, c.Lapses)
+	}
+	if len(cd.UpNext) != 1 || cd.UpNext[0] != c {
+		t.Errorf("Expected the card to be on the up next queue, got %v", cd.UpNext)
+	}
+	if c.TotalTimesReviewed == 0 {
+		t.Errorf("Expected the review counts to be kept")
+	}
+}
+
+func TestLeechRelearnsOnlyTheFacet(t *testing.T) {
+	cd := createLeechCardData(t, LeechRelearn)
+	cd.Cards[1] = createKanjiCard(1)
+	cd.Cards[1].splitFacets()
+	cd.Cards[1].ReadingReview.Lapses = 2
+
+	result, err := cd.AnswerCard(1, ReadingFacet, Again, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+	c := cd.Cards[1]
+	if !result.Leech || c.Review(ReadingFacet).LearningStage != UpNext || c.LearningStage != Learned {
+		t.Errorf("Expected only the reading to be relearned, got meaning %s and reading %s", c.Review(MeaningFacet).LearningStageString(), c.Review(ReadingFacet).LearningStageString())
+	}
+	if len(cd.UpNext) != 1 {
+		t.Errorf("Expected the card to stay on the up next queue for its reading")
+	}
+}
+
+func TestGetLeeches(t *testing.T) {
+	cd := createLeechCardData(t, LeechTag)
+	for i := 1; i <= 3; i++ {
+		c := createKanjiCard(i)
+		c.ComponentSubjectIDs = []int{10}
+		cd.Cards[i] = c
+	}
+	cd.Cards[1].splitFacets()
+	cd.Cards[1].Lapses = 3
+	cd.Cards[1].ReadingMnemonic = "A reading mnemonic"
+	cd.Cards[2].Readings = []Reading{{Reading: "ねこ", AcceptedAnswer: true}} // Confusable: same component and reading
+	cd.Cards[3].Readings = []Reading{{Reading: "いぬ", AcceptedAnswer: true}} // Only the same component
+
+	leeches := cd.GetLeeches()
+	if len(leeches) != 1 || leeches[0].Card.ID != 1 {
+		t.Fatalf("Expected card 1 to be the only leech, got %v", leeches)
+	}
+	l := leeches[0]
+	if len(l.Facets) != 1 || l.Facets[0] != MeaningFacet || l.Lapses != 3 {
+		t.Errorf("Expected the meaning to be the leech with 3 lapses, got %v %d", l.Facets, l.Lapses)
+	}
+	if len(l.Siblings) != 1 || l.Siblings[0].ID != 2 {
+		t.Errorf("Expected card 2 to be a confusable sibling, got %v", l.Siblings)
+	}
+	if !containsString(l.Suggestions, "Add a meaning mnemonic") {
+		t.Errorf("Expected a meaning mnemonic to be suggested, got %v", l.Suggestions)
+	}
+}
+
+func TestLeechesPage(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+	cd.Cards[1].Meanings = []Meaning{{Meaning: "One", Primary: true, AcceptedAnswer: true}}
+	cd.Cards[1].Tags = []string{LeechTagName}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/cardoverview/leeches", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Add a meaning mnemonic") {
+		t.Errorf("Expected the leech to be listed with suggestions, got %d", w.Code)
+	}
+}
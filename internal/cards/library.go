@@ -0,0 +1,165 @@
+package cards
+
+import (
+	"log"
+	"sync"
+)
+
+// Library is the card content shared by every profile.
+// Each profile holds its own copy of the cards so that it can keep its own progress on them,
+// so a change to the content has to be made to every profile. Content changes go through
+// updateContent, which makes the same change to every profile, or to none of them.
+type Library struct {
+	mu       sync.Mutex // Serialises content changes, so every profile sees them in the same order
+	profiles []*CardData
+}
+
+func (l *Library) AddProfile(cd *CardData) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cd.Library = l
+	l.profiles = append(l.profiles, cd)
+}
+
+// Make a content change to every profile, starting with cd.
+// change is called once per profile, with that profile locked.
+// Every profile is changed before anything is saved, and if the change fails for one profile it is undone for all of them.
+// Card data without a library is a single profile, so only it is changed.
+func (cd *CardData) updateContent(change func(p *CardData) error) error {
+	if cd.Library == nil {
+		return cd.Update(func() error {
+			return change(cd)
+		})
+	}
+
+	l := cd.Library
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Profiles are only ever locked together here, under the library lock, so this can't deadlock
+	profiles := []*CardData{cd}
+	for _, p := range l.profiles {
+		if p != cd {
+			profiles = append(profiles, p)
+		}
+	}
+	for _, p := range profiles {
+		if p.readOnly {
+			return ErrReadOnly
+		}
+	}
+	for _, p := range profiles {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+	}
+
+	var before []contentState
+	undo := func() {
+		for i, s := range before {
+			profiles[i].restoreContentState(s)
+			profiles[i].UpdateCardData()
+		}
+	}
+	for _, p := range profiles {
+		before = append(before, p.contentState())
+		err := change(p)
+		if err != nil {
+			undo()
+			return err
+		}
+	}
+
+	// The content is shared, so save it once, and only then the progress of each profile.
+	// If it can't be saved the change is undone everywhere, so it is never live for some profiles and not others.
+	for _, p := range profiles {
+		p.relinkUpNext()
+		p.UpdateCardData()
+	}
+	err := cd.saveContent()
+	if err != nil {
+		undo()
+		return err
+	}
+
+	// The change is now made for everyone. A profile whose progress fails to save here tries again on its next save.
+	for _, p := range profiles {
+		p.savedContent = cd.savedContent
+		err := p.commit()
+		if err != nil {
+			log.Printf("Error saving progress for %s: %s", p.Profile, err)
+		}
+	}
+	return nil
+}
+
+// What a content change can touch in a profile, to put back if the change fails
+type contentState struct {
+	cards    map[int]*Card
+	upNext   []int
+	orphaned map[int]CardProgress
+}
+
+// Caller must hold the lock
+func (cd *CardData) contentState() contentState {
+	s := contentState{
+		cards:    make(map[int]*Card, len(cd.Cards)),
+		orphaned: make(map[int]CardProgress, len(cd.orphanedProgress)),
+	}
+	for id, c := range cd.Cards {
+		s.cards[id] = c.Copy()
+	}
+	for _, c := range cd.UpNext {
+		s.upNext = append(s.upNext, c.ID)
+	}
+	for id, p := range cd.orphanedProgress {
+		s.orphaned[id] = p
+	}
+	return s
+}
+
+// Caller must hold the lock
+func (cd *CardData) restoreContentState(s contentState) {
+	cd.Cards = s.cards
+	cd.UpNext = nil
+	for _, id := range s.upNext {
+		cd.UpNext = append(cd.UpNext, s.cards[id])
+	}
+	cd.orphanedProgress = s.orphaned
+}
+
+// Set the content of a card, keeping this profile's progress on it
+// Caller must hold the lock
+func (cd *CardData) putContent(id int, content *Card) {
+	c := content.Content()
+	c.ID = id
+	if existing, ok := cd.Cards[id]; ok {
+		c.SetProgress(existing.Progress())
+	} else if p, ok := cd.orphanedProgress[id]; ok {
+		c.SetProgress(p)
+		delete(cd.orphanedProgress, id)
+	}
+	cd.Cards[id] = c
+}
+
+// Replace all the content with the given cards, keeping this profile's progress.
+// Progress on cards that are not in the new content is kept aside in case they come back.
+// Caller must hold the lock
+func (cd *CardData) replaceContent(content map[int]*Card) {
+	old := cd.Cards
+	cd.Cards = make(map[int]*Card, len(content))
+	for id, c := range content {
+		if existing, ok := old[id]; ok {
+			cd.Cards[id] = existing
+		}
+		cd.putContent(id, c)
+	}
+
+	if cd.orphanedProgress == nil {
+		cd.orphanedProgress = make(map[int]CardProgress)
+	}
+	for id, c := range old {
+		if _, ok := content[id]; !ok {
+			cd.orphanedProgress[id] = c.Progress()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package cards
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+var errInternal = errors.New("internal server error, see the server log for details")
+
+// Wrap the router with request logging and panic recovery
+func withMiddleware(h http.Handler) http.Handler {
+	return LogRequests(Recover(h))
+}
+
+// Remembers the status code written, for the middleware
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Log every request with its status code and how long it took
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, sr.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// Turn a panic in a handler into a 500 response, so one bad request can't take down the server
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sr := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// Used to abort a response on purpose, and the server doesn't log it
+				panic(p)
+			}
+			log.Printf("Panic handling %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			if sr.status != 0 {
+				// Too late to change the response
+				return
+			}
+			status := http.StatusInternalServerError
+			if isAPIRequest(r) {
+				writeJSONError(sr, status, errInternal)
+			} else {
+				http.Error(sr, errInternal.Error(), status)
+			}
+		}()
+		next.ServeHTTP(sr, r)
+	})
+}
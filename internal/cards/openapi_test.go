@@ -0,0 +1,120 @@
+package cards
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadOpenAPIDocument(t *testing.T) openAPIDocument {
+	b, err := os.ReadFile("../../static/openapi.json")
+	if err != nil {
+		t.Fatalf("Error reading openapi.json: %s", err)
+	}
+	var doc openAPIDocument
+	err = json.Unmarshal(b, &doc)
+	if err != nil {
+		t.Fatalf("Error parsing openapi.json: %s", err)
+	}
+	return doc
+}
+
+// Matches the pattern in a route variable, e.g. the ":[0-9]+" in "{id:[0-9]+}"
+var routeVarPattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// Every route as "method path" in OpenAPI's form, e.g. "get /card/{id}".
+// Prefix routes serving files become "/static/{path}". Routes without methods are "any /login".
+func registeredRoutes(t *testing.T, router *mux.Router) []string {
+	var routes []string
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil // A subrouter
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		re, err := route.GetPathRegexp()
+		if err != nil {
+			return err
+		}
+		p := routeVarPattern.ReplaceAllString(tmpl, "{$1}")
+		if !strings.HasSuffix(re, "$") {
+			p += "{path}"
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"any"}
+		}
+		for _, m := range methods {
+			routes = append(routes, strings.ToLower(m)+" "+p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error walking routes: %s", err)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+
+	routes := registeredRoutes(t, createTestServer(t).Router())
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		registered[route] = true
+		parts := strings.SplitN(route, " ", 2)
+		ops := doc.Paths[parts[1]]
+		if _, ok := ops[parts[0]]; !ok && (parts[0] != "any" || len(ops) == 0) {
+			t.Errorf("Expected route %s to be documented in static/openapi.json", route)
+		}
+	}
+
+	for p, ops := range doc.Paths {
+		for m := range ops {
+			if m == "parameters" {
+				continue
+			}
+			if !registered[m+" "+p] && !registered["any "+p] {
+				t.Errorf("Expected documented route %s %s to be registered", m, p)
+			}
+		}
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	s := createTestServer(t)
+
+	// No login needed
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON, got %s", w.Header().Get("Content-Type"))
+	}
+	var doc openAPIDocument
+	err := json.Unmarshal(w.Body.Bytes(), &doc)
+	if err != nil || len(doc.Paths) == 0 {
+		t.Errorf("Expected the OpenAPI document, got %s", err)
+	}
+}
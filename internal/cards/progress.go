@@ -0,0 +1,277 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Cards are stored in two files:
+//   - The cards file holds the content: characters, meanings, mnemonics, components, etc.
+//     It can be shared, and replaced with an updated deck using ImportContent.
+//   - The progress file holds the review state of each card, keyed by card ID.
+//
+// In memory a Card holds both, so the rest of the code doesn't need to care.
+// Tags are split between the two. See progressTags.
+// Cards files from before the split also hold progress. It is used if there is no progress file yet.
+
+// CardProgress is the personal review state of a card
+type CardProgress struct {
+	Interval           int           `json:"interval,omitempty"`
+	LearningInterval   int           `json:"learning_interval,omitempty"`
+	NextReviewDate     string        `json:"next_review_date,omitempty"`
+	TotalTimesReviewed int           `json:"total_times_reviewed,omitempty"`
+	TotalTimesCorrect  int           `json:"total_times_correct,omitempty"`
+	QueuedToLearn      bool          `json:"queued_to_learn,omitempty"`
+	Stability          float64       `json:"stability,omitempty"`
+	Difficulty         float64       `json:"difficulty,omitempty"`
+	LastReviewDate     string        `json:"last_review_date,omitempty"`
+	LearningStage      LearningStage `json:"learning_stage,omitempty"`
+	Lapses             int           `json:"lapses,omitempty"`
+	Tags               []string      `json:"tags,omitempty"`
+	ReadingReview      *FacetReview  `json:"reading_review,omitempty"`
+	UserSynonyms       []string      `json:"user_synonyms,omitempty"`
+	BlockedAnswers     []string      `json:"blocked_answers,omitempty"`
+}
+
+// Tags that record what a user has done with a card, rather than describe the card.
+// They are kept with the progress. Any other tag is content, and is shared by every profile.
+var progressTags = []string{"suspended", LeechTagName}
+
+// Split tags into content tags and progress tags
+func splitTags(tags []string) ([]string, []string) {
+	var content, progress []string
+	for _, t := range tags {
+		if containsString(progressTags, t) {
+			progress = append(progress, t)
+		} else {
+			content = append(content, t)
+		}
+	}
+	return content, progress
+}
+
+func (c *Card) Progress() CardProgress {
+	return CardProgress{
+		Interval:           c.Interval,
+		LearningInterval:   c.LearningInterval,
+		NextReviewDate:     c.NextReviewDate,
+		TotalTimesReviewed: c.TotalTimesReviewed,
+		TotalTimesCorrect:  c.TotalTimesCorrect,
+		QueuedToLearn:      c.QueuedToLearn,
+		Stability:          c.Stability,
+		Difficulty:         c.Difficulty,
+		LastReviewDate:     c.LastReviewDate,
+		LearningStage:      c.LearningStage,
+		Lapses:             c.Lapses,
+		Tags:               progressTagsOf(c.Tags),
+		ReadingReview:      copyFacetReview(c.ReadingReview),
+		UserSynonyms:       copyStrings(c.UserSynonyms),
+		BlockedAnswers:     copyStrings(c.BlockedAnswers),
+	}
+}
+
+func (c *Card) SetProgress(p CardProgress) {
+	c.Interval = p.Interval
+	c.LearningInterval = p.LearningInterval
+	c.NextReviewDate = p.NextReviewDate
+	c.TotalTimesReviewed = p.TotalTimesReviewed
+	c.TotalTimesCorrect = p.TotalTimesCorrect
+	c.QueuedToLearn = p.QueuedToLearn
+	c.Stability = p.Stability
+	c.Difficulty = p.Difficulty
+	c.LastReviewDate = p.LastReviewDate
+	c.LearningStage = p.LearningStage
+	c.Lapses = p.Lapses
+	// Keep the content tags, and replace the progress ones
+	tags, _ := splitTags(c.Tags)
+	for _, t := range p.Tags {
+		if !containsString(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	c.Tags = tags
+	c.ReadingReview = copyFacetReview(p.ReadingReview)
+	c.UserSynonyms = copyStrings(p.UserSynonyms)
+	c.BlockedAnswers = copyStrings(p.BlockedAnswers)
+}
+
+func progressTagsOf(tags []string) []string {
+	_, progress := splitTags(tags)
+	return progress
+}
+
+// Content returns a copy of the card without any progress
+func (c *Card) Content() *Card {
+	n := c.Copy()
+	n.SetProgress(CardProgress{})
+	return n
+}
+
+const ProgressFileVersion = 1
+
+type ProgressFile struct {
+	Version  int                  `json:"version"`
+	Progress map[int]CardProgress `json:"progress"`
+}
+
+func (cd *CardData) ProgressFile() string {
+	return filepath.Join(cd.GetProfileDir(), "progress.json")
+}
+
+// Read a progress file. A missing file is not an error, but returns nil.
+func ReadProgressFile(path string) (map[int]CardProgress, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f ProgressFile
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, err
+	}
+	if f.Version > ProgressFileVersion {
+		return nil, fmt.Errorf("progress file is version %d, but this version of Moe Kyuniversity only understands up to version %d", f.Version, ProgressFileVersion)
+	}
+	if f.Progress == nil {
+		f.Progress = make(map[int]CardProgress)
+	}
+	return f.Progress, nil
+}
+
+// Put the saved progress onto freshly loaded cards.
+// Progress for cards that are no longer in the content is kept aside, so it comes back if the card does.
+// Caller must hold the lock
+func (cd *CardData) applyProgress(progress map[int]CardProgress) {
+	cd.orphanedProgress = make(map[int]CardProgress)
+	for id, p := range progress {
+		c, ok := cd.Cards[id]
+		if !ok {
+			cd.orphanedProgress[id] = p
+			continue
+		}
+		c.SetProgress(p)
+	}
+	for _, c := range cd.Cards {
+		if _, ok := progress[c.ID]; !ok {
+			c.SetProgress(CardProgress{})
+		}
+	}
+	if len(cd.orphanedProgress) > 0 {
+		log.Printf("Keeping progress for %d cards that are not in the cards file", len(cd.orphanedProgress))
+	}
+}
+
+// Caller must hold the lock
+func (cd *CardData) saveProgress() error {
+	progress := make(map[int]CardProgress, len(cd.Cards)+len(cd.orphanedProgress))
+	for id, p := range cd.orphanedProgress {
+		progress[id] = p
+	}
+	for id, c := range cd.Cards {
+		progress[id] = c.Progress()
+	}
+
+	data, err := json.Marshal(ProgressFile{
+		Version:  ProgressFileVersion,
+		Progress: progress,
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cd.ProgressFile(), data)
+}
+
+// contentJSON marshals a card without its progress.
+// The nil fields shadow the card's progress fields of the same name, and omitempty leaves them out.
+// Tags shadows the card's tags with just the content ones.
+type contentJSON struct {
+	*Card
+	Interval           *int     `json:"interval,omitempty"`
+	LearningInterval   *int     `json:"learning_interval,omitempty"`
+	NextReviewDate     *string  `json:"next_review_date,omitempty"`
+	TotalTimesReviewed *int     `json:"total_times_reviewed,omitempty"`
+	TotalTimesCorrect  *int     `json:"total_times_correct,omitempty"`
+	QueuedToLearn      *bool    `json:"queued_to_learn,omitempty"`
+	Stability          *int     `json:"stability,omitempty"`
+	Difficulty         *int     `json:"difficulty,omitempty"`
+	LastReviewDate     *string  `json:"last_review_date,omitempty"`
+	LearningStage      *int     `json:"learning_stage,omitempty"`
+	Lapses             *int     `json:"lapses,omitempty"`
+	Tags               []string `json:"tags,omitempty"`
+	ReadingReview      *int     `json:"reading_review,omitempty"`
+	UserSynonyms       *int     `json:"user_synonyms,omitempty"`
+	BlockedAnswers     *int     `json:"blocked_answers,omitempty"`
+}
+
+func MarshalContentFile(cards map[int]*Card) ([]byte, error) {
+	content := make(map[int]contentJSON, len(cards))
+	for id, c := range cards {
+		tags, _ := splitTags(c.Tags)
+		content[id] = contentJSON{Card: c, Tags: tags}
+	}
+
+	return json.Marshal(struct {
+		Version int                 `json:"version"`
+		Cards   map[int]contentJSON `json:"cards"`
+	}{CardsFileVersion, content})
+}
+
+// Caller must hold the lock
+func (cd *CardData) saveContent() error {
+	data, err := MarshalContentFile(cd.Cards)
+	if err != nil {
+		return err
+	}
+
+	// Content rarely changes, so don't rewrite it after every review
+	if bytes.Equal(data, cd.savedContent) {
+		return nil
+	}
+	err = writeFileAtomic(cd.CardsFile, data)
+	if err != nil {
+		return err
+	}
+	cd.savedContent = data
+	return nil
+}
+
+// Replace the content of the cards with those in another cards file, keeping progress.
+// Cards that are new in the file are added. Cards that are not in the file are left alone.
+// Returns the number of cards added and updated.
+func (cd *CardData) ImportContent(path string) (int, int, error) {
+	imported, err := ReadCardsFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	added, updated := 0, 0
+	err = cd.updateContent(func(p *CardData) error {
+		err := p.BackupCardMap()
+		if err != nil {
+			return err
+		}
+
+		for id, c := range imported {
+			// Count the changes as the importing profile sees them
+			if p == cd {
+				if _, ok := cd.Cards[id]; ok {
+					updated++
+				} else {
+					added++
+				}
+			}
+			p.putContent(id, c)
+		}
+		return nil
+	})
+	return added, updated, err
+}
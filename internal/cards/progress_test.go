@@ -0,0 +1,144 @@
+package cards
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveSplitsContentAndProgress(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	cd.Cards[1].Characters = "一"
+	cd.Cards[1].Tags = []string{"suspended"}
+
+	err := cd.SaveCardMap()
+	if err != nil {
+		t.Fatalf("Error saving cards: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(cd.CardsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "一") {
+		t.Errorf("Expected the cards file to hold the content, got %s", content)
+	}
+	for _, field := range []string{"interval", "next_review_date", "tags", "learning_stage"} {
+		if strings.Contains(string(content), `"`+field+`"`) {
+			t.Errorf("Expected the cards file not to hold %s, got %s", field, content)
+		}
+	}
+
+	progress, err := ReadProgressFile(cd.ProgressFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := progress[1]
+	if p.Interval != 48 || p.NextReviewDate != "2020-01-01T00:00:00Z" || len(p.Tags) != 1 {
+		t.Errorf("Expected the progress file to hold the progress, got %+v", p)
+	}
+}
+
+func TestContentTagsStayInContent(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	cd.Cards[1].Tags = []string{"added_from_dictionary", "suspended"}
+
+	err := cd.SaveCardMap()
+	if err != nil {
+		t.Fatalf("Error saving cards: %s", err)
+	}
+	cards, err := ReadCardsFile(cd.CardsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := cards[1].Tags; len(tags) != 1 || tags[0] != "added_from_dictionary" {
+		t.Errorf("Expected only the content tag in the cards file, got %v", tags)
+	}
+	progress, err := ReadProgressFile(cd.ProgressFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := progress[1].Tags; len(tags) != 1 || tags[0] != "suspended" {
+		t.Errorf("Expected only the progress tag in the progress file, got %v", tags)
+	}
+}
+
+func TestImportContentKeepsProgress(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+	cd.BackupDir = filepath.Join(cd.DataDir, "backup")
+	cd.Cards[1].Meanings = []Meaning{{Meaning: "Old", Primary: true, AcceptedAnswer: true}}
+	cd.Cards[1].UserSynonyms = []string{"Uno"}
+	cd.Cards[1].Tags = []string{"suspended", "old"}
+
+	// A new deck with updated content for card 1, a new card 3, and no card 2
+	deck := map[int]*Card{
+		1: {ID: 1, Characters: "一", Meanings: []Meaning{{Meaning: "One", Primary: true, AcceptedAnswer: true}}, Tags: []string{"jlpt5"}},
+		3: {ID: 3, Characters: "三", Meanings: []Meaning{{Meaning: "Three", Primary: true, AcceptedAnswer: true}}},
+	}
+	data, err := MarshalContentFile(deck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deckFile := filepath.Join(t.TempDir(), "deck.json")
+	err = ioutil.WriteFile(deckFile, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added, updated, err := cd.ImportContent(deckFile)
+	if err != nil {
+		t.Fatalf("Error importing content: %s", err)
+	}
+	if added != 1 || updated != 1 {
+		t.Errorf("Expected 1 added and 1 updated, got %d and %d", added, updated)
+	}
+
+	c := cd.Cards[1]
+	if c.Meanings[0].Meaning != "One" || c.Characters != "一" {
+		t.Errorf("Expected card 1 content to be updated, got %+v", c)
+	}
+	if c.Interval != 48 || c.LearningStage != Learned {
+		t.Errorf("Expected card 1 progress to be kept, got interval %d stage %d", c.Interval, c.LearningStage)
+	}
+	if len(c.UserSynonyms) != 1 || c.UserSynonyms[0] != "Uno" {
+		t.Errorf("Expected card 1 synonyms to be kept, got %v", c.UserSynonyms)
+	}
+	if len(c.Tags) != 2 || !containsString(c.Tags, "jlpt5") || !containsString(c.Tags, "suspended") {
+		t.Errorf("Expected card 1 to have the deck's tags and stay suspended, got %v", c.Tags)
+	}
+	if _, ok := cd.Cards[2]; !ok {
+		t.Errorf("Expected card 2 to be kept")
+	}
+	if cd.Cards[3].Interval != 0 || cd.Cards[3].NextReviewDate != "" {
+		t.Errorf("Expected card 3 to have no progress, got %+v", cd.Cards[3])
+	}
+}
+
+func TestLoadAppliesProgressFile(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+	err := cd.SaveCardMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Progress for a card that has been removed from the content is kept
+	cd.orphanedProgress = map[int]CardProgress{5: {Interval: 100}}
+	err = cd.saveProgress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := CardData{
+		CardsFile: cd.CardsFile,
+		DataDir:   cd.DataDir,
+		BackupDir: filepath.Join(cd.DataDir, "backup"),
+	}
+	loaded.LoadCardJson()
+	if loaded.Cards[1].Interval != 48 || loaded.Cards[1].LearningStage != Learned {
+		t.Errorf("Expected card 1 progress to load, got %+v", loaded.Cards[1])
+	}
+	if loaded.orphanedProgress[5].Interval != 100 {
+		t.Errorf("Expected progress for card 5 to be kept, got %+v", loaded.orphanedProgress)
+	}
+}
@@ -0,0 +1,145 @@
+package cards
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReviewLogEntry is a single answer to a single card.
+// The review log is append-only. Entries are never modified once written,
+// so undoing an answer appends an undo entry rather than removing the answer.
+type ReviewLogEntry struct {
+	CardID           int           `json:"card_id"`
+	Facet            Facet         `json:"facet,omitempty"` // Empty for reviews from before cards had facets
+	Timestamp        string        `json:"timestamp"`       // RFC3339 date string
+	Grade            Grade         `json:"grade"`
+	PreviousStage    LearningStage `json:"previous_stage"`
+	NewStage         LearningStage `json:"new_stage"`
+	PreviousInterval int           `json:"previous_interval"` // Hours
+	NewInterval      int           `json:"new_interval"`      // Hours
+	ResponseTime     int           `json:"response_time"`     // Milliseconds from the card being shown to being answered. 0 if unknown.
+	Undo             bool          `json:"undo,omitempty"`    // Undoes the latest answer before it to the same facet of the card
+}
+
+func (cd *CardData) ReviewLogFile() string {
+	return filepath.Join(cd.GetProfileDir(), "revlog.jsonl")
+}
+
+// Record a review of a facet in the review log.
+// prevStage and prevInterval are the facet's state before the answer was processed.
+func (cd *CardData) LogReview(c *Card, f Facet, g Grade, prevStage LearningStage, prevInterval int, responseTime int) error {
+	r := c.Review(f)
+	entry := ReviewLogEntry{
+		CardID:           c.ID,
+		Facet:            f,
+		Timestamp:        cd.Now().Format(time.RFC3339),
+		Grade:            g,
+		PreviousStage:    prevStage,
+		NewStage:         r.LearningStage,
+		PreviousInterval: prevInterval,
+		NewInterval:      r.CurrentInterval(),
+		ResponseTime:     responseTime,
+	}
+
+	return cd.appendReviewLog(entry)
+}
+
+// Append an entry to this card data's review log, and to today's answers. See days.go.
+func (cd *CardData) appendReviewLog(entry ReviewLogEntry) error {
+	d := cd.todayLog()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := AppendReviewLog(cd.ReviewLogFile(), entry); err != nil {
+		return err
+	}
+	d.add(cd.DayBoundary, entry)
+	return nil
+}
+
+func AppendReviewLog(path string, entry ReviewLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// Read every entry in the review log, oldest first.
+// Answers that were undone are left out, along with the undo entries.
+// A missing review log is treated as empty.
+func ReadReviewLog(path string) ([]ReviewLogEntry, error) {
+	var entries []ReviewLogEntry
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry ReviewLogEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Undo {
+			entries = removeUndoneAnswer(entries, entry)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Remove the latest answer that undo undoes
+func removeUndoneAnswer(entries []ReviewLogEntry, undo ReviewLogEntry) []ReviewLogEntry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].CardID == undo.CardID && entries[i].Facet == undo.Facet {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+func (cd *CardData) GetReviewLog() ([]ReviewLogEntry, error) {
+	return ReadReviewLog(cd.ReviewLogFile())
+}
+
+func (cd *CardData) GetCardReviewLog(id int) ([]ReviewLogEntry, error) {
+	entries, err := cd.GetReviewLog()
+	if err != nil {
+		return nil, err
+	}
+
+	var cardEntries []ReviewLogEntry
+	for _, e := range entries {
+		if e.CardID == id {
+			cardEntries = append(cardEntries, e)
+		}
+	}
+	return cardEntries, nil
+}
@@ -0,0 +1,66 @@
+package cards
+
+import (
+	"testing"
+)
+
+func TestReviewLog(t *testing.T) {
+	c1 := CreateCard(1, 0, 0, "1970-01-01T00:00:00Z")
+	c1.LearningStage = UpNext
+	c2 := CreateCard(2, 48, 0, "2020-01-01T00:00:00Z")
+	c2.LearningStage = Learned
+	cd := CreateCardDataFromSlice([]*Card{c1, c2})
+	cd.DataDir = t.TempDir()
+
+	// Nothing has been reviewed yet
+	entries, err := cd.GetReviewLog()
+	if err != nil {
+		t.Fatalf("Error reading empty review log: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected 0 entries, got %d", len(entries))
+	}
+
+	c1.CorrectAnswer()
+	err = cd.LogReview(c1, MeaningFacet, Good, UpNext, 0, 1500)
+	if err != nil {
+		t.Fatalf("Error writing review log: %s", err)
+	}
+	c2.IncorrectAnswer()
+	err = cd.LogReview(c2, MeaningFacet, Again, Learned, 48, 0)
+	if err != nil {
+		t.Fatalf("Error writing review log: %s", err)
+	}
+
+	entries, err = cd.GetReviewLog()
+	if err != nil {
+		t.Fatalf("Error reading review log: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.CardID != 1 || e.Grade != Good || e.PreviousStage != UpNext || e.NewStage != Learning {
+		t.Errorf("Unexpected first entry: %+v", e)
+	}
+	if e.PreviousInterval != 0 || e.NewInterval != 3 || e.ResponseTime != 1500 {
+		t.Errorf("Unexpected first entry intervals: %+v", e)
+	}
+
+	e = entries[1]
+	if e.CardID != 2 || e.Grade != Again || e.PreviousStage != Learned || e.NewStage != Learning {
+		t.Errorf("Unexpected second entry: %+v", e)
+	}
+	if e.PreviousInterval != 48 || e.NewInterval != 3 {
+		t.Errorf("Unexpected second entry intervals: %+v", e)
+	}
+
+	entries, err = cd.GetCardReviewLog(2)
+	if err != nil {
+		t.Fatalf("Error reading card review log: %s", err)
+	}
+	if len(entries) != 1 || entries[0].CardID != 2 {
+		t.Errorf("Expected 1 entry for card 2, got %+v", entries)
+	}
+}
@@ -0,0 +1,250 @@
+package cards
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Grade is how well a card was recalled.
+// Values follow the Anki / FSRS rating convention.
+type Grade int
+
+const (
+	Again Grade = iota + 1 // Forgotten. The same as an incorrect answer.
+	Hard                   // Recalled with difficulty. The interval grows less than usual.
+	Good                   // Recalled. The same as a correct answer.
+	Easy                   // Recalled without effort. The interval grows more than usual.
+)
+
+var Grades = []Grade{Again, Hard, Good, Easy}
+
+func (g Grade) String() string {
+	switch g {
+	case Again:
+		return "again"
+	case Hard:
+		return "hard"
+	case Good:
+		return "good"
+	case Easy:
+		return "easy"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseGrade(s string) (Grade, error) {
+	for _, g := range Grades {
+		if strings.EqualFold(s, g.String()) || s == strconv.Itoa(int(g)) {
+			return g, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown grade %q", s)
+}
+
+// How much the interval grows for each passing grade
+func gradeMultiplier(g Grade) float64 {
+	switch g {
+	case Hard:
+		return 1.5
+	case Easy:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// Scheduler decides how a card's intervals change after it has been answered.
+// The SRS handlers only talk to this interface, so the algorithm can be changed per deployment.
+type Scheduler interface {
+	Name() string
+	ProcessAnswer(c *Card, g Grade, now time.Time)
+	Settings() SrsSettings
+}
+
+// SrsSettings are the parameters shared by every scheduler. Zero values use the defaults.
+type SrsSettings struct {
+	InitialLearningInterval int // Hours until a card that has just been learned or forgotten is reviewed again
+	BurnInterval            int // Cards whose interval reaches this many hours are burned
+
+	LeechThreshold int         // Lapses in a row before a facet is a leech
+	LeechAction    LeechAction // What to do with a card once it's a leech
+
+	NewCardsPerDay int // Most new cards that can be started each day. 0 for no limit.
+	ReviewsPerDay  int // Most learned reviews each day. 0 for no limit.
+
+	NoFuzz bool // Don't spread out learned reviews. See fuzz.go.
+}
+
+var DefaultSrsSettings = SrsSettings{
+	InitialLearningInterval: 3,
+	BurnInterval:            8760, // 365 days
+	LeechThreshold:          4,
+	LeechAction:             LeechTag,
+}
+
+// The settings with defaults filled in
+func (st SrsSettings) Settings() SrsSettings {
+	if st.InitialLearningInterval <= 0 {
+		st.InitialLearningInterval = DefaultSrsSettings.InitialLearningInterval
+	}
+	if st.BurnInterval <= 0 {
+		st.BurnInterval = DefaultSrsSettings.BurnInterval
+	}
+	if st.LeechThreshold <= 0 {
+		st.LeechThreshold = DefaultSrsSettings.LeechThreshold
+	}
+	if st.LeechAction == "" {
+		st.LeechAction = DefaultSrsSettings.LeechAction
+	}
+	return st
+}
+
+var SchedulerNames = []string{"doubling", "fsrs"}
+
+func NewScheduler(name string, st SrsSettings) (Scheduler, error) {
+	switch name {
+	case "", "doubling":
+		return DoublingScheduler{SrsSettings: st}, nil
+	case "fsrs":
+		s := NewFsrsScheduler()
+		s.SrsSettings = st
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler %q, expected one of %v", name, SchedulerNames)
+	}
+}
+
+func (cd *CardData) GetScheduler() Scheduler {
+	if cd.Scheduler == nil {
+		return DoublingScheduler{}
+	}
+	return cd.Scheduler
+}
+
+// DoublingScheduler is the original Moe Kyuniversity algorithm.
+// Intervals are doubled on a correct answer and halved on an incorrect one.
+// See the README for a walkthrough.
+type DoublingScheduler struct {
+	SrsSettings
+}
+
+func (s DoublingScheduler) Name() string {
+	return "doubling"
+}
+
+func (s DoublingScheduler) ProcessAnswer(c *Card, g Grade, now time.Time) {
+	if g == Again {
+		s.processIncorrectAnswer(c, now)
+		return
+	}
+
+	st := s.Settings()
+	if c.LearningStage == Learning { // Learning stage
+		learningStepCorrect(c, g)
+	} else if c.LearningStage == Learned { // Learned stage
+		c.Interval = growInterval(c.Interval, g)
+
+		// If the Interval reaches the burn interval (365 days by default), then the card has graduated to the burned stage and will no longer be reviewed.
+		if c.Interval >= st.BurnInterval {
+			c.LearningStage = Burned
+		}
+	} else if c.LearningStage == UpNext { // Up next stage
+		upNextCorrect(c, g, st)
+	}
+
+	c.IncrementReviewCount()
+	c.IncrementCorrectAnswerCount()
+	c.SetNextReviewDate(now)
+}
+
+func (s DoublingScheduler) processIncorrectAnswer(c *Card, now time.Time) {
+	st := s.Settings()
+	if c.LearningStage == Learning { // Learning stage
+		learningStepIncorrect(c, st, now)
+	} else if c.LearningStage == Learned { // Learned stage
+		c.Interval /= 2
+
+		// Card gets downgraded to the learning stage
+		c.LearningStage = Learning
+		c.LearningInterval = st.InitialLearningInterval
+
+		c.IncrementReviewCount()
+		c.SetNextFailedReviewDate(now)
+	} else if c.LearningStage == UpNext { // Up next stage
+		upNextIncorrect(c)
+	}
+}
+
+// The learning steps are shared between schedulers.
+// They cover the first day of a card's life, before there is enough history to do anything clever.
+
+func upNextCorrect(c *Card, g Grade, st SrsSettings) {
+	// If the card is in the up next stage, then it is being reviewed for the first time.
+	// Easy cards skip the learning stage and go straight to learned with a 1 day interval.
+	if g == Easy {
+		c.LearningStage = Learned
+		c.Interval = 24
+		c.LearningInterval = 0
+		return
+	}
+
+	// Otherwise set the LearningStage to Learning, and set the LearningInterval to the initial learning interval.
+	c.LearningStage = Learning
+	c.LearningInterval = st.InitialLearningInterval
+}
+
+func upNextIncorrect(c *Card) {
+	// If the card is in the up next stage, then it is being reviewed for the first time.
+	// If the answer is incorrect, then the card is rescheduled for the NextReviewDate + 10 minutes.
+	// Since the card's NextReviewDate is initialised to 1970-01-01, this will result in the card being reviewed immediately after the current queue of up next cards are done.
+	nrd, err := time.Parse(time.RFC3339, c.NextReviewDate)
+	if err != nil {
+		log.Printf("Card %d has an invalid NextReviewDate: %s", c.ID, c.NextReviewDate)
+		panic(err)
+	}
+	c.NextReviewDate = nrd.Add(10 * time.Minute).Format(time.RFC3339)
+}
+
+func learningStepCorrect(c *Card, g Grade) {
+	c.LearningInterval = growInterval(c.LearningInterval, g)
+
+	// If the LearningInterval is more than 24 hours, then the card has graduated to the learned
+	if c.LearningInterval >= 24 {
+		c.LearningStage = Learned
+		// Set the Interval to the max of LearningInterval and Interval
+		// This accounts for cards that have been learned before, but are now being learned again due to forgetting the answer.
+		if c.LearningInterval > c.Interval {
+			c.Interval = c.LearningInterval
+		}
+		// Then set the LearningInterval to 0, as it is no longer needed.
+		c.LearningInterval = 0
+	}
+}
+
+func learningStepIncorrect(c *Card, st SrsSettings, now time.Time) {
+	// Only affect the LearningInterval.
+	// The Interval is not affected, to preserve progress.
+	c.LearningInterval /= 2
+
+	// LearningInterval cannot be less than the initial learning interval.
+	if c.LearningInterval < st.InitialLearningInterval {
+		c.LearningInterval = st.InitialLearningInterval
+	}
+	c.IncrementReviewCount()
+	c.SetNextFailedReviewDate(now)
+}
+
+// Grow an interval in hours according to the grade.
+// Always grows by at least an hour, so Hard answers on short intervals still make progress.
+func growInterval(hours int, g Grade) int {
+	grown := int(math.Round(float64(hours) * gradeMultiplier(g)))
+	if grown <= hours {
+		grown = hours + 1
+	}
+	return grown
+}
@@ -0,0 +1,217 @@
+package cards
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewScheduler(t *testing.T) {
+	s, err := NewScheduler("doubling", DefaultSrsSettings)
+	if err != nil || s.Name() != "doubling" {
+		t.Errorf("Expected doubling scheduler, got %v (%v)", s, err)
+	}
+	s, err = NewScheduler("fsrs", DefaultSrsSettings)
+	if err != nil || s.Name() != "fsrs" {
+		t.Errorf("Expected fsrs scheduler, got %v (%v)", s, err)
+	}
+	_, err = NewScheduler("nonsense", DefaultSrsSettings)
+	if err == nil {
+		t.Errorf("Expected an error for an unknown scheduler")
+	}
+
+	// Card data without a scheduler falls back to the doubling scheduler
+	cd := CreateCardDataFromSlice([]*Card{})
+	if cd.GetScheduler().Name() != "doubling" {
+		t.Errorf("Expected default scheduler to be doubling, got %s", cd.GetScheduler().Name())
+	}
+}
+
+func TestFsrsCorrectUpNextToLearning(t *testing.T) {
+	c := Card{
+		ID:             1,
+		LearningStage:  UpNext,
+		NextReviewDate: "1970-01-01T00:00:00Z",
+	}
+
+	c.CorrectAnswerWith(NewFsrsScheduler(), time.Now())
+	if c.LearningStage != Learning {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learning, c.LearningStage)
+	}
+	if c.LearningInterval != 3 {
+		t.Errorf("Incorrect learning interval. Expected 3, got %d", c.LearningInterval)
+	}
+	if c.Stability != DefaultFsrsWeights[Good-1] {
+		t.Errorf("Incorrect stability. Expected %f, got %f", DefaultFsrsWeights[Good-1], c.Stability)
+	}
+	if c.Difficulty != DefaultFsrsWeights[4] {
+		t.Errorf("Incorrect difficulty. Expected %f, got %f", DefaultFsrsWeights[4], c.Difficulty)
+	}
+	if c.LastReviewDate == "" {
+		t.Errorf("Expected the last review date to be set")
+	}
+}
+
+func TestFsrsCorrectLearnedToLearned(t *testing.T) {
+	c := Card{
+		ID:             1,
+		Interval:       48,
+		LearningStage:  Learned,
+		NextReviewDate: "2020-01-01T00:00:00Z", // Any date in the past
+		Stability:      2,
+		Difficulty:     5,
+		LastReviewDate: time.Now().Add(-48 * time.Hour).Format(time.RFC3339),
+	}
+
+	c.CorrectAnswerWith(NewFsrsScheduler(), time.Now())
+	if c.LearningStage != Learned {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learned, c.LearningStage)
+	}
+	if c.Stability <= 2 {
+		t.Errorf("Expected stability to increase from 2, got %f", c.Stability)
+	}
+	// With 90% desired retention, the interval is the stability in days
+	expectedInterval := NewFsrsScheduler().intervalHours(c.Stability)
+	if c.Interval != expectedInterval || c.Interval <= 48 {
+		t.Errorf("Incorrect interval. Expected %d, got %d", expectedInterval, c.Interval)
+	}
+	ExpectedNextReviewDate := time.Now().Add(time.Duration(c.Interval) * time.Hour).Round(time.Hour).Format(time.RFC3339)
+	if c.NextReviewDate != ExpectedNextReviewDate {
+		t.Errorf("Incorrect next review date. Expected %s, got %s", ExpectedNextReviewDate, c.NextReviewDate)
+	}
+	if c.TotalTimesReviewed != 1 || c.TotalTimesCorrect != 1 {
+		t.Errorf("Incorrect review counts. Expected 1 / 1, got %d / %d", c.TotalTimesCorrect, c.TotalTimesReviewed)
+	}
+}
+
+func TestFsrsIncorrectLearnedToLearning(t *testing.T) {
+	c := Card{
+		ID:             1,
+		Interval:       240,
+		LearningStage:  Learned,
+		NextReviewDate: "2020-01-01T00:00:00Z", // Any date in the past
+		Stability:      10,
+		Difficulty:     5,
+		LastReviewDate: time.Now().Add(-240 * time.Hour).Format(time.RFC3339),
+	}
+
+	c.IncorrectAnswerWith(NewFsrsScheduler(), time.Now())
+	if c.LearningStage != Learning {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learning, c.LearningStage)
+	}
+	if c.LearningInterval != 3 {
+		t.Errorf("Incorrect learning interval. Expected 3, got %d", c.LearningInterval)
+	}
+	if c.Stability >= 10 {
+		t.Errorf("Expected stability to decrease from 10, got %f", c.Stability)
+	}
+	if c.Difficulty <= 5 {
+		t.Errorf("Expected difficulty to increase from 5, got %f", c.Difficulty)
+	}
+	if c.Interval >= 240 {
+		t.Errorf("Expected interval to decrease from 240, got %d", c.Interval)
+	}
+	ExpectedNextReviewDate := time.Now().Add(10 * time.Minute).Round(time.Minute).Format(time.RFC3339)
+	if c.NextReviewDate != ExpectedNextReviewDate {
+		t.Errorf("Incorrect next review date. Expected %s, got %s", ExpectedNextReviewDate, c.NextReviewDate)
+	}
+}
+
+func TestFsrsSeedsMemoryStateFromInterval(t *testing.T) {
+	// Card learned with the doubling scheduler, so it has no memory state
+	c := Card{
+		ID:             1,
+		Interval:       240, // 10 days
+		LearningStage:  Learned,
+		NextReviewDate: "2020-01-01T00:00:00Z",
+	}
+
+	c.CorrectAnswerWith(NewFsrsScheduler(), time.Now())
+	if c.Stability <= 10 {
+		t.Errorf("Expected stability to be seeded from the interval and grow past 10 days, got %f", c.Stability)
+	}
+	if c.Interval <= 240 {
+		t.Errorf("Expected interval to grow past 240, got %d", c.Interval)
+	}
+}
+
+func TestFsrsLearnedToBurned(t *testing.T) {
+	c := Card{
+		ID:             1,
+		Interval:       24 * 300,
+		LearningStage:  Learned,
+		NextReviewDate: "2020-01-01T00:00:00Z",
+		Stability:      300,
+		Difficulty:     3,
+		LastReviewDate: time.Now().Add(-300 * 24 * time.Hour).Format(time.RFC3339),
+	}
+
+	c.CorrectAnswerWith(NewFsrsScheduler(), time.Now())
+	if c.LearningStage != Burned {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Burned, c.LearningStage)
+	}
+	if c.NextReviewDate != "" {
+		t.Errorf("Incorrect next review date. Expected empty string, got %s", c.NextReviewDate)
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	for _, g := range Grades {
+		parsed, err := ParseGrade(g.String())
+		if err != nil || parsed != g {
+			t.Errorf("Expected %s to parse, got %d (%v)", g, parsed, err)
+		}
+	}
+	parsed, err := ParseGrade("3")
+	if err != nil || parsed != Good {
+		t.Errorf("Expected 3 to parse as good, got %d (%v)", parsed, err)
+	}
+	_, err = ParseGrade("perfect")
+	if err == nil {
+		t.Errorf("Expected an error for an unknown grade")
+	}
+}
+
+func TestFsrsGradesOrderIntervals(t *testing.T) {
+	var intervals []int
+	for _, g := range []Grade{Hard, Good, Easy} {
+		c := Card{
+			ID:             1,
+			Interval:       96,
+			LearningStage:  Learned,
+			NextReviewDate: "2020-01-01T00:00:00Z",
+			Stability:      4,
+			Difficulty:     5,
+			LastReviewDate: time.Now().Add(-96 * time.Hour).Format(time.RFC3339),
+		}
+		c.AnswerWith(NewFsrsScheduler(), g, time.Now())
+		intervals = append(intervals, c.Interval)
+	}
+
+	if !(intervals[0] < intervals[1] && intervals[1] < intervals[2]) {
+		t.Errorf("Expected hard < good < easy intervals, got %v", intervals)
+	}
+}
+
+func TestSrsSettings(t *testing.T) {
+	s, err := NewScheduler("doubling", SrsSettings{InitialLearningInterval: 5, BurnInterval: 96})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Card{ID: 1, LearningStage: UpNext, NextReviewDate: "1970-01-01T00:00:00Z"}
+	c.AnswerWith(s, Good, time.Now())
+	if c.LearningInterval != 5 {
+		t.Errorf("Expected learning interval 5, got %d", c.LearningInterval)
+	}
+
+	c = Card{ID: 1, LearningStage: Learned, Interval: 48, NextReviewDate: "1970-01-01T00:00:00Z"}
+	c.AnswerWith(s, Good, time.Now())
+	if c.LearningStage != Burned {
+		t.Errorf("Expected the card to be burned at 96 hours, got stage %d", c.LearningStage)
+	}
+
+	// Unset settings use the defaults
+	if st := (DoublingScheduler{}).Settings(); st != DefaultSrsSettings {
+		t.Errorf("Expected the default settings, got %v", st)
+	}
+}
@@ -0,0 +1,168 @@
+package cards
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// The cards file is a versioned envelope:
+//
+//	{"version": 1, "cards": {"1": {...}, "2": {...}}}
+//
+// Files from before the envelope existed are a bare map of cards, and count as version 0.
+// When the cards file changes shape, bump CardsFileVersion and add a Migration from the old version.
+// LoadCardJson runs the migrations in order, backing up the file before each one.
+
+const CardsFileVersion = 1
+
+type CardsFile struct {
+	Version int           `json:"version"`
+	Cards   map[int]*Card `json:"cards"`
+}
+
+// Migration upgrades the raw cards file from version From to From+1
+type Migration struct {
+	From        int
+	Description string
+	Migrate     func(data []byte) ([]byte, error)
+}
+
+var Migrations = []Migration{
+	{From: 0, Description: "Wrap the card map in a versioned envelope", Migrate: migrateWrapEnvelope},
+}
+
+func migrateWrapEnvelope(data []byte) ([]byte, error) {
+	var cards map[int]json.RawMessage
+	err := json.Unmarshal(data, &cards)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Version int                     `json:"version"`
+		Cards   map[int]json.RawMessage `json:"cards"`
+	}{1, cards})
+}
+
+// Get the version of a raw cards file
+func CardsFileVersionOf(data []byte) (int, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return 0, err
+	}
+
+	// Card IDs are numbers, so a bare card map can never have these keys
+	v, hasVersion := fields["version"]
+	_, hasCards := fields["cards"]
+	if !hasVersion || !hasCards {
+		return 0, nil
+	}
+
+	var version int
+	err = json.Unmarshal(v, &version)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cards file version: %w", err)
+	}
+	return version, nil
+}
+
+// Upgrade a raw cards file to the current version.
+// beforeEach is called with the file as it is before each migration, and can stop the migration by returning an error.
+// Returns the upgraded file and the version it started at.
+func MigrateCardsFile(data []byte, beforeEach func(data []byte, m Migration) error) ([]byte, int, error) {
+	version, err := CardsFileVersionOf(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > CardsFileVersion {
+		return nil, version, fmt.Errorf("cards file is version %d, but this version of Moe Kyuniversity only understands up to version %d", version, CardsFileVersion)
+	}
+
+	from := version
+	for version < CardsFileVersion {
+		m, err := getMigration(version)
+		if err != nil {
+			return nil, from, err
+		}
+
+		if beforeEach != nil {
+			err = beforeEach(data, m)
+			if err != nil {
+				return nil, from, err
+			}
+		}
+
+		data, err = m.Migrate(data)
+		if err != nil {
+			return nil, from, fmt.Errorf("migrating cards file from version %d: %w", m.From, err)
+		}
+		version++
+	}
+
+	return data, from, nil
+}
+
+func getMigration(from int) (Migration, error) {
+	for _, m := range Migrations {
+		if m.From == from {
+			return m, nil
+		}
+	}
+	return Migration{}, fmt.Errorf("no migration from cards file version %d", from)
+}
+
+// Parse a cards file that is already at the current version
+func ParseCardsFile(data []byte) (map[int]*Card, error) {
+	var f CardsFile
+	err := json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, err
+	}
+	if f.Version != CardsFileVersion {
+		return nil, fmt.Errorf("expected cards file version %d, got %d", CardsFileVersion, f.Version)
+	}
+	if f.Cards == nil {
+		f.Cards = make(map[int]*Card)
+	}
+	return f.Cards, nil
+}
+
+func MarshalCardsFile(cards map[int]*Card) ([]byte, error) {
+	return json.Marshal(CardsFile{
+		Version: CardsFileVersion,
+		Cards:   cards,
+	})
+}
+
+// Read a cards file of any version. Old versions are migrated in memory only.
+func ReadCardsFile(path string) (map[int]*Card, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err = MigrateCardsFile(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCardsFile(data)
+}
+
+// Keep a copy of the cards file as it was before a migration, in case the migration goes wrong
+func (cd *CardData) backupBeforeMigration(data []byte, m Migration) error {
+	err := cd.ensureBackupDir()
+	if err != nil {
+		return err
+	}
+
+	t := time.Now()
+	backupFilename := filepath.Join(cd.BackupDir, fmt.Sprintf("%s%s-v%d%s", backupPrefix, t.Format(time.RFC3339), m.From, backupSuffix))
+	log.Printf("Migrating cards file from version %d: %s. Backing up to %s", m.From, m.Description, backupFilename)
+	return writeFileAtomic(backupFilename, data)
+}
@@ -0,0 +1,81 @@
+package cards
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCardsFileVersionOf(t *testing.T) {
+	v, err := CardsFileVersionOf([]byte(`{"1":{"id":1}}`))
+	if err != nil || v != 0 {
+		t.Errorf("Expected a bare card map to be version 0, got %d (%v)", v, err)
+	}
+	v, err = CardsFileVersionOf([]byte(`{"version":1,"cards":{}}`))
+	if err != nil || v != 1 {
+		t.Errorf("Expected version 1, got %d (%v)", v, err)
+	}
+}
+
+func TestMigrateTooNew(t *testing.T) {
+	_, _, err := MigrateCardsFile([]byte(`{"version":1000,"cards":{}}`), nil)
+	if err == nil {
+		t.Errorf("Expected an error for a cards file newer than this version")
+	}
+}
+
+func TestLoadVersion0CardsFile(t *testing.T) {
+	dir := t.TempDir()
+	cd := CardData{
+		CardsFile: filepath.Join(dir, "cards.json"),
+		DataDir:   dir,
+		BackupDir: filepath.Join(dir, "backup"),
+	}
+	old := `{"1":{"id":1,"object":"kanji","characters":"一","interval":48,"next_review_date":"2020-01-01T00:00:00Z"}}`
+	err := ioutil.WriteFile(cd.CardsFile, []byte(old), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cd.LoadCardJson()
+	if len(cd.Cards) != 1 || cd.Cards[1].Characters != "一" || cd.Cards[1].Interval != 48 {
+		t.Errorf("Expected the version 0 card to load, got %+v", cd.Cards[1])
+	}
+
+	// The file is saved in the current format
+	data, err := ioutil.ReadFile(cd.CardsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := CardsFileVersionOf(data)
+	if err != nil || v != CardsFileVersion {
+		t.Errorf("Expected the saved file to be version %d, got %d (%v)", CardsFileVersion, v, err)
+	}
+
+	// The original file was backed up before migrating
+	backups, err := cd.ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, b := range backups {
+		data, err := ioutil.ReadFile(b.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) == old {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a backup of the version 0 file, got %d backups", len(backups))
+	}
+
+	// Backups of either version can be read
+	for _, b := range backups {
+		cards, err := ReadBackup(b)
+		if err != nil || len(cards) != 1 {
+			t.Errorf("Expected backup %s to load 1 card, got %d (%v)", b.Name, len(cards), err)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package cards
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Server serves one profile per user, or a single profile with no login when there are no users.
+// Requests are routed to the profile of the logged in user by the session cookie.
+
+const sessionCookie = "session"
+const sessionLifetime = 30 * 24 * time.Hour
+
+type Server struct {
+	Users    *UserStore
+	Profiles map[string]*CardData // By user name
+	Default  *CardData            // The only profile when there are no users
+
+	base     *CardData // Settings shared by every profile. Renders pages that don't belong to a profile.
+	sessions sessionStore
+}
+
+// Load the cards for every user, or for base if there are no users.
+func NewServer(base *CardData, users *UserStore) (*Server, error) {
+	base.SetupFuncMap()
+	err := base.LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Users:    users,
+		Profiles: make(map[string]*CardData),
+		base:     base,
+		sessions: sessionStore{sessions: make(map[string]session)},
+	}
+
+	names := users.Names()
+	if len(names) == 0 {
+		base.LoadCardJson()
+		s.Default = base
+		return s, nil
+	}
+
+	library := &Library{}
+	for _, name := range names {
+		p := base.ForProfile(name)
+		err = os.MkdirAll(p.ProfileDir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loading profile %s", name)
+		p.LoadCardJson()
+		library.AddProfile(p)
+		s.Profiles[name] = p
+	}
+	return s, nil
+}
+
+// Card data for a user's profile, sharing the cards file and settings of cd. The cards are not loaded.
+func (cd *CardData) ForProfile(name string) *CardData {
+	profileDir := ProfileDirFor(cd.DataDir, name)
+	return &CardData{
+		CardsFile:         cd.CardsFile,
+		DataDir:           cd.DataDir,
+		ProfileDir:        profileDir,
+		Profile:           name,
+		BackupDir:         profileBackupDir(cd.BackupDir, profileDir, name),
+		StaticDir:         cd.StaticDir,
+		Dev:               cd.Dev,
+		DictionaryFile:    cd.DictionaryFile,
+		KanjiFrequencyDir: cd.KanjiFrequencyDir,
+		Scheduler:         cd.Scheduler,
+		BackupRetention:   cd.BackupRetention,
+		DayBoundary:       cd.DayBoundary,
+		Clock:             cd.Clock,
+		FuncMap:           cd.FuncMap,
+		templates:         cd.templates,
+	}
+}
+
+// Profile backups go in a directory per user under the backup directory, or in the profile if there isn't one
+func profileBackupDir(backupDir string, profileDir string, name string) string {
+	if backupDir == "" {
+		return filepath.Join(profileDir, "backup")
+	}
+	return filepath.Join(backupDir, "users", name)
+}
+
+// Every profile, sorted by user name
+func (s *Server) AllProfiles() []*CardData {
+	if s.Default != nil {
+		return []*CardData{s.Default}
+	}
+	var profiles []*CardData
+	for _, name := range s.Users.Names() {
+		profiles = append(profiles, s.Profiles[name])
+	}
+	return profiles
+}
+
+// The named user's profile. With no users, the name must be empty.
+func (s *Server) Profile(name string) (*CardData, error) {
+	if s.Default != nil {
+		if name != "" {
+			return nil, errors.New("there are no users")
+		}
+		return s.Default, nil
+	}
+	if name == "" {
+		return nil, errors.New("a user must be given when there are users")
+	}
+	p, ok := s.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown user %s", name)
+	}
+	return p, nil
+}
+
+// Load the dictionary once and share it between every profile
+func (s *Server) LoadDictionary() {
+	profiles := s.AllProfiles()
+	profiles[0].LoadDictionary()
+	for _, p := range profiles[1:] {
+		p.ShareDictionary(profiles[0])
+	}
+}
+
+func (s *Server) Router() *mux.Router {
+	r := NewRouter(s.base, s.profileFor)
+	r.HandleFunc("/login", s.LoginHandler)
+	r.HandleFunc("/logout", s.LogoutHandler).Methods("POST")
+	r.HandleFunc(apiPrefix+"/login", s.ApiLoginHandler).Methods("POST")
+	r.HandleFunc(apiPrefix+"/logout", s.ApiLogoutHandler).Methods("POST")
+	return r
+}
+
+// How the server listens. Without a certificate and key it serves plain HTTP.
+type ListenConfig struct {
+	Addr            string
+	TLSCertFile     string
+	TLSKeyFile      string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // How long to wait for requests to finish when shutting down
+}
+
+// Serve until ctx is done, then stop accepting requests, wait for those in progress and save everything.
+// Also records historical data for every profile at midnight.
+func (s *Server) Run(ctx context.Context, lc ListenConfig) error {
+	if (lc.TLSCertFile == "") != (lc.TLSKeyFile == "") {
+		return errors.New("both a TLS certificate and key are needed for TLS")
+	}
+	srv := &http.Server{
+		Addr:         lc.Addr,
+		Handler:      withMiddleware(s.Router()),
+		ReadTimeout:  lc.ReadTimeout,
+		WriteTimeout: lc.WriteTimeout,
+		IdleTimeout:  lc.IdleTimeout,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, p := range s.AllProfiles() {
+		go DoHistoricalData(ctx, p)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if lc.TLSCertFile != "" {
+			log.Printf("Listening on %s with TLS", lc.Addr)
+			errc <- srv.ListenAndServeTLS(lc.TLSCertFile, lc.TLSKeyFile)
+		} else {
+			log.Printf("Listening on %s", lc.Addr)
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		// Failed to start, e.g. the address is in use
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), lc.ShutdownTimeout)
+	defer shutdownCancel()
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Error waiting for requests to finish: %s", err)
+	}
+	return s.Close()
+}
+
+// Save every profile's cards and today's historical data
+func (s *Server) Close() error {
+	var firstErr error
+	for _, p := range s.AllProfiles() {
+		err := p.Flush()
+		if err == nil {
+			err = p.Snapshot().SaveHistoricalData()
+		}
+		if err != nil {
+			log.Printf("Error saving profile %q: %s", p.Profile, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// The profile for the session in the session cookie, or in an "Authorization: Bearer <token>" header.
+// Pages redirect to the login page if nobody is logged in.
+func (s *Server) profileFor(r *http.Request) (*CardData, error) {
+	if s.Default != nil {
+		return s.Default, nil
+	}
+	name, ok := s.sessions.get(sessionToken(r))
+	if !ok {
+		return nil, ErrNotLoggedIn
+	}
+	return s.Profiles[name], nil
+}
+
+// The token in the Authorization header or session cookie. Empty if there is neither, as in single user mode.
+func sessionToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// Identifies the client a request came from, for what is kept per client, such as the undo stack.
+// API clients are known by their bearer token, and browsers by their CSRF cookie, which every page sets.
+// Unlike the session token, one of them is always there, including in single user mode.
+func clientID(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return "bearer:" + strings.TrimPrefix(auth, "Bearer ")
+	}
+	return "browser:" + CSRFToken(r)
+}
+
+// End a session, along with the undo stack of the client that made the request
+func (s *Server) endSession(r *http.Request, token string) {
+	if name, ok := s.sessions.get(token); ok {
+		s.Profiles[name].DropUndo(clientID(r))
+	}
+	s.sessions.end(token)
+}
+
+type LoginData struct {
+	Name  string
+	Error string
+}
+
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Default != nil {
+		// Nothing to log in to
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	if r.Method != "POST" {
+		s.base.doTemplate(w, r, "login.html", LoginData{})
+		return
+	}
+
+	name := r.FormValue("name")
+	err := s.Users.Authenticate(name, r.FormValue("password"))
+	if err != nil {
+		log.Printf("Failed login for %q: %s", name, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		s.base.doTemplate(w, r, "login.html", LoginData{Name: name, Error: ErrBadLogin.Error()})
+		return
+	}
+
+	token, err := s.sessions.start(name)
+	if err != nil {
+		log.Printf("Error starting session: %s", err)
+		http.Error(w, errInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(sessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Printf("%s logged in", name)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil {
+		s.endSession(r, cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+type ApiLoginResult struct {
+	Token string `json:"token"` // Send as "Authorization: Bearer <token>"
+}
+
+// POST /api/v1/login
+// Log in with HTTP basic authentication, and get a token for the Authorization header
+func (s *Server) ApiLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Default != nil {
+		writeJSONError(w, http.StatusNotFound, errors.New("there are no users, so no login is needed"))
+		return
+	}
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, errors.New("log in with basic authentication"))
+		return
+	}
+	err := s.Users.Authenticate(name, password)
+	if err != nil {
+		log.Printf("Failed API login for %q: %s", name, err)
+		writeJSONError(w, http.StatusUnauthorized, ErrBadLogin)
+		return
+	}
+
+	token, err := s.sessions.start(name)
+	if err != nil {
+		log.Printf("Error starting session: %s", err)
+		writeJSONError(w, http.StatusInternalServerError, errInternal)
+		return
+	}
+	log.Printf("%s logged in to the API", name)
+	writeJSON(w, http.StatusOK, ApiLoginResult{Token: token})
+}
+
+// POST /api/v1/logout
+// End the session of the token in the Authorization header
+func (s *Server) ApiLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		s.endSession(r, strings.TrimPrefix(auth, "Bearer "))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sessions are only held in memory, so everyone has to log in again after a restart
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session // By token
+}
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+func (ss *sessionStore) start(user string) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.sessions[token] = session{user: user, expires: time.Now().Add(sessionLifetime)}
+	return token, nil
+}
+
+func (ss *sessionStore) get(token string) (string, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	sess, ok := ss.sessions[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(sess.expires) {
+		delete(ss.sessions, token)
+		return "", false
+	}
+	return sess.user, true
+}
+
+func (ss *sessionStore) end(token string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.sessions, token)
+}
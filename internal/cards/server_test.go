@@ -0,0 +1,407 @@
+package cards
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A server with users alice and bob, who both start with the progress of 3 learned cards
+func createTestServer(t *testing.T) *Server {
+	base := createStoreCardData(t, 3)
+	base.StaticDir = "../../static"
+	err := base.SaveCardMap()
+	if err != nil {
+		t.Fatalf("Error saving cards: %s", err)
+	}
+
+	users, err := LoadUsers(UsersFile(base.DataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		err = users.Add(name, name+"'s password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = SeedProfile(base.DataDir, base.CardsFile, ProfileDirFor(base.DataDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewServer(&CardData{
+		CardsFile: base.CardsFile,
+		DataDir:   base.DataDir,
+		StaticDir: base.StaticDir,
+	}, users)
+	if err != nil {
+		t.Fatalf("Error creating server: %s", err)
+	}
+	return s
+}
+
+// Get a CSRF token cookie, as a browser would when loading a page
+func getCSRFCookie(t *testing.T, h http.Handler) *http.Cookie {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookie {
+			return c
+		}
+	}
+	t.Fatalf("Expected a CSRF cookie")
+	return nil
+}
+
+func login(t *testing.T, h http.Handler, name string, password string) *httptest.ResponseRecorder {
+	csrf := getCSRFCookie(t, h)
+	form := url.Values{"name": {name}, "password": {password}, csrfField: {csrf.Value}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(csrf)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerLogin(t *testing.T) {
+	s := createTestServer(t)
+	h := s.Router()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/card/1/json", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Errorf("Expected a redirect to /login, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	w = login(t, h, "alice", "bob's password")
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for the wrong password, got %d", w.Code)
+	}
+
+	w = login(t, h, "alice", "alice's password")
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusFound || len(cookies) != 1 || cookies[0].Name != sessionCookie {
+		t.Fatalf("Expected a session cookie, got %d %v", w.Code, cookies)
+	}
+	if !cookies[0].HttpOnly {
+		t.Errorf("Expected the session cookie to be HttpOnly")
+	}
+
+	req := httptest.NewRequest("GET", "/card/1/json", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 once logged in, got %d", w.Code)
+	}
+
+	// Profiles and passwords are not served as data files
+	for _, path := range []string{"/data/users.json", "/data/users/bob/progress.json"} {
+		req = httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookies[0])
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for %s, got %d", path, w.Code)
+		}
+	}
+
+	// Logging out drops the browser's undo stack
+	alice := s.Profiles["alice"]
+	alice.AnswerCardInSession("browser:c", 1, MeaningFacet, Good, 0)
+	if !alice.CanUndo("browser:c") {
+		t.Fatalf("Expected the answer to be undoable")
+	}
+	req = httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(cookies[0])
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "c"})
+	req.Header.Set(csrfHeader, "c")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if alice.CanUndo("browser:c") {
+		t.Errorf("Expected the undo stack to be dropped on logout")
+	}
+}
+
+func TestProfilesShareContentButNotProgress(t *testing.T) {
+	s := createTestServer(t)
+	alice, bob := s.Profiles["alice"], s.Profiles["bob"]
+
+	_, err := alice.AnswerCard(1, MeaningFacet, Good, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+	if alice.Cards[1].Interval != 96 {
+		t.Errorf("Expected alice's interval to be 96, got %d", alice.Cards[1].Interval)
+	}
+	if bob.Cards[1].Interval != 48 {
+		t.Errorf("Expected bob's interval to stay 48, got %d", bob.Cards[1].Interval)
+	}
+	if cards := loadSavedCards(t, bob); cards[1].Interval != 48 {
+		t.Errorf("Expected bob's saved interval to stay 48, got %d", cards[1].Interval)
+	}
+
+	// Editing a card changes the content for everyone, but not bob's progress
+	c := alice.Snapshot().Cards[1]
+	c.Characters = "猫"
+	err = alice.SaveCard(1, c)
+	if err != nil {
+		t.Fatalf("Error saving card: %s", err)
+	}
+	if bob.Cards[1].Characters != "猫" || bob.Cards[1].Interval != 48 {
+		t.Errorf("Expected bob to see the new characters with bob's own progress, got %s and %d", bob.Cards[1].Characters, bob.Cards[1].Interval)
+	}
+
+	id, err := bob.AddNewCard(&Card{Object: "vocabulary", Characters: "犬", Tags: []string{"added_from_dictionary"}})
+	if err != nil {
+		t.Fatalf("Error adding card: %s", err)
+	}
+	if c, ok := alice.Cards[id]; !ok || c.Characters != "犬" || !containsString(c.Tags, "added_from_dictionary") {
+		t.Errorf("Expected alice to have the new card %d with its tags", id)
+	}
+
+	err = bob.RemoveCard(2)
+	if err != nil {
+		t.Fatalf("Error removing card: %s", err)
+	}
+	if _, ok := alice.Cards[2]; ok {
+		t.Errorf("Expected card 2 to be removed for alice")
+	}
+}
+
+func TestContentChangesAreAllOrNothing(t *testing.T) {
+	s := createTestServer(t)
+	alice, bob := s.Profiles["alice"], s.Profiles["bob"]
+
+	// Removing a card bob doesn't have fails for bob, so alice keeps it too
+	delete(bob.Cards, 3)
+	alice.Cards[3].Characters = "猫"
+	err := alice.RemoveCard(3)
+	if err == nil {
+		t.Fatalf("Expected removing the card to fail")
+	}
+	if c, ok := alice.Cards[3]; !ok || c.Characters != "猫" {
+		t.Errorf("Expected alice to keep card 3 as it was")
+	}
+	if cards := loadSavedCards(t, alice); cards[3] == nil {
+		t.Errorf("Expected alice's saved cards to keep card 3")
+	}
+
+	// If the content can't be saved, the card isn't added for anyone
+	cardsFile := alice.CardsFile
+	alice.CardsFile = filepath.Join(t.TempDir(), "missing", "cards.json")
+	na, nb := len(alice.Cards), len(bob.Cards)
+	if _, err = alice.AddNewCard(&Card{Object: "vocabulary", Characters: "犬"}); err == nil {
+		t.Fatalf("Expected adding the card to fail")
+	}
+	if len(alice.Cards) != na || len(bob.Cards) != nb {
+		t.Errorf("Expected neither profile to have the new card")
+	}
+	alice.CardsFile = cardsFile
+
+	// Once the content is saved the change has been made, even if bob's progress can't be saved yet
+	bob.ProfileDir = filepath.Join(t.TempDir(), "missing")
+	c := alice.Snapshot().Cards[1]
+	c.Characters = "犬"
+	if err = alice.SaveCard(1, c); err != nil {
+		t.Errorf("Expected the edit to succeed, got %s", err)
+	}
+	if bob.Cards[1].Characters != "犬" {
+		t.Errorf("Expected bob to see the edit")
+	}
+}
+
+func TestProfileBackupDir(t *testing.T) {
+	cd := &CardData{DataDir: "data", BackupDir: "/srv/backup"}
+	if dir := cd.ForProfile("alice").BackupDir; dir != filepath.Join("/srv/backup", "users", "alice") {
+		t.Errorf("Expected the profile's backups under the backup directory, got %s", dir)
+	}
+}
+
+func TestLegacyProgressGoesToSeededUser(t *testing.T) {
+	// A cards file from before progress was split out, with no progress file
+	base := createStoreCardData(t, 1)
+	data, err := MarshalCardsFile(base.Cards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeFileAtomic(base.CardsFile, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bob is added first and seeded, alice comes later but loads first
+	users, _ := LoadUsers(UsersFile(base.DataDir))
+	users.Add("bob", "bob's password")
+	err = SeedProfile(base.DataDir, base.CardsFile, ProfileDirFor(base.DataDir, "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.Add("alice", "alice's password")
+
+	s, err := NewServer(&CardData{CardsFile: base.CardsFile, DataDir: base.DataDir, StaticDir: "../../static"}, users)
+	if err != nil {
+		t.Fatalf("Error creating server: %s", err)
+	}
+	if i := s.Profiles["bob"].Cards[1].Interval; i != 48 {
+		t.Errorf("Expected bob to have the old progress, got interval %d", i)
+	}
+	if i := s.Profiles["alice"].Cards[1].Interval; i != 0 {
+		t.Errorf("Expected alice to start afresh, got interval %d", i)
+	}
+}
+
+func TestCSRFProtection(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+	cd.StaticDir = "../../static"
+	cd.BackupDir = filepath.Join(cd.DataDir, "backup")
+	cd.SetupFuncMap()
+	h := NewRouter(cd, singleProfile(cd))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/card/1/delete", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for deleting with GET, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/card/1", nil))
+	var csrf *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookie {
+			csrf = c
+		}
+	}
+	if csrf == nil {
+		t.Fatalf("Expected a CSRF cookie with the page")
+	}
+	if !strings.Contains(w.Body.String(), csrf.Value) {
+		t.Errorf("Expected the page to include the CSRF token")
+	}
+
+	// Another site can make the browser send the cookie, but can't read it to send the token
+	for _, token := range []string{"", "wrong"} {
+		req := httptest.NewRequest("POST", "/card/1/delete", nil)
+		req.AddCookie(csrf)
+		req.Header.Set(csrfHeader, token)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for token %q, got %d", token, w.Code)
+		}
+	}
+	if _, ok := cd.Cards[1]; !ok {
+		t.Fatalf("Expected card 1 not to be deleted without a valid token")
+	}
+
+	req := httptest.NewRequest("POST", "/card/1/delete", nil)
+	req.AddCookie(csrf)
+	req.Header.Set(csrfHeader, csrf.Value)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Errorf("Expected status 302 with the token, got %d", w.Code)
+	}
+	if _, ok := cd.Cards[1]; ok {
+		t.Errorf("Expected card 1 to be deleted with the token")
+	}
+}
+
+func TestApiLogin(t *testing.T) {
+	s := createTestServer(t)
+	h := s.Router()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/cards", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without logging in, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/login", nil)
+	req.SetBasicAuth("bob", "alice's password")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for the wrong password, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/login", nil)
+	req.SetBasicAuth("bob", "bob's password")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var login ApiLoginResult
+	json.Unmarshal(w.Body.Bytes(), &login)
+	if w.Code != http.StatusOK || login.Token == "" {
+		t.Fatalf("Expected a token, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": 1, "grade": "good"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for an unknown token, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/srs/answers", strings.NewReader(`{"card_id": 1, "grade": "good"}`))
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the token, got %d %s", w.Code, w.Body.String())
+	}
+	if s.Profiles["bob"].Cards[1].Interval != 96 || s.Profiles["alice"].Cards[1].Interval != 48 {
+		t.Errorf("Expected only bob's card to be answered")
+	}
+}
+
+func TestServerRunSavesOnShutdown(t *testing.T) {
+	s := createTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run(ctx, ListenConfig{Addr: "127.0.0.1:0", ShutdownTimeout: time.Second})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Error running server: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the server to shut down")
+	}
+
+	today := time.Now().Format("2006-01-02")
+	for _, p := range s.AllProfiles() {
+		data, err := p.GetHistoricalData()
+		if err != nil {
+			t.Fatalf("Error reading historical data: %s", err)
+		}
+		if entries := data.HistoricalDataEntries; len(entries) != 1 || entries[0].DateTime != today {
+			t.Errorf("Expected today's historical data to be recorded for %s, got %v", p.Profile, entries)
+		}
+
+		// Recording again on the same day replaces the day's line
+		err = p.Snapshot().SaveHistoricalData()
+		if err != nil {
+			t.Fatalf("Error saving historical data: %s", err)
+		}
+		if data, _ = p.GetHistoricalData(); len(data.HistoricalDataEntries) != 1 {
+			t.Errorf("Expected one line per day, got %v", data.HistoricalDataEntries)
+		}
+	}
+}
+
+func TestServerRunNeedsCertAndKey(t *testing.T) {
+	s := createTestServer(t)
+	err := s.Run(context.Background(), ListenConfig{Addr: "127.0.0.1:0", TLSCertFile: "cert.pem"})
+	if err == nil {
+		t.Errorf("Expected an error with a certificate but no key")
+	}
+}
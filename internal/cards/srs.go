@@ -12,55 +12,79 @@ type SrsData struct {
 	DueCount            int
 	LearningCount       int
 	Card                *Card
+	Facet               Facet       // The facet of the card being reviewed
+	Review              FacetReview // The facet's review state
 	MeaningMnemonicHtml template.HTML
 	ReadingMnemonicHtml template.HTML
 	SentenceHtml        SentenceHtml
 	Tokens              []Token
+	CanUndo             bool // The session has an answer it can undo
+	HeldBack            int  // Learned reviews that are due, but over the daily limit
 }
 
 func (cd *CardData) GetNextSrsCard() SrsData {
-	// Get all cards that are due
-	c := cd.ToList()
-	dueCards := filterCardsByDueBefore(c, time.Now())
-	dueCards = filterOutCardsByTag(dueCards, "suspended")
+	if cd.CurrentVacation() != nil {
+		return SrsData{}
+	}
+
+	// Get all facets that are due. Each facet of a card is reviewed on its own.
+	c := filterOutCardsByTag(cd.ToList(), "suspended")
+	now := cd.Now()
 
 	// Prioritise cards that are new
 	// Prioritise cards that are in the learning stage
 	// Don't sort by due date to add randomness to review order
-	learningCards := filterCardsByLearningStage(dueCards, Learning)
-	learnedCards := filterCardsByLearningStage(dueCards, Learned)
+	learningItems := dueReviewItems(c, Learning, now)
+	learnedItems := dueReviewItems(c, Learned, now)
+
+	// Learned reviews stop at the daily limit.
+	// Learning reviews carry on, as their intervals are too short to put off until tomorrow.
+	heldBack := 0
+	if left := cd.reviewsLeft(); left >= 0 && len(learnedItems) > left {
+		heldBack = len(learnedItems) - left
+		learnedItems = learnedItems[:left]
+	}
 
-	upNextCards := cd.GetUpNextCards()
+	// Up next cards are reviewed one facet at a time, and stay in the queue until every facet has been answered
+	var upNextItems []ReviewItem
+	for _, card := range cd.GetUpNextCards() {
+		for _, f := range card.Facets() {
+			if card.Review(f).LearningStage == UpNext {
+				upNextItems = append(upNextItems, ReviewItem{Card: card, Facet: f})
+			}
+		}
+	}
 
 	// Up next cards are placed after you've reviewed everything
-	srsDueCards := append(learningCards, learnedCards...)
+	srsDueItems := append(learningItems, learnedItems...)
 
 	// Display the number of reviews.
 	// If there are no due cards, then display the number of up next cards.
 	// As this means the user has reviewed everything and is now looking at new cards.
 	var l int
-	if len(srsDueCards) == 0 {
-		l = len(upNextCards)
+	if len(srsDueItems) == 0 {
+		l = len(upNextItems)
 	} else {
-		l = len(srsDueCards)
+		l = len(srsDueItems)
 	}
 
-	srsDueCards = append(srsDueCards, upNextCards...)
+	srsDueItems = append(srsDueItems, upNextItems...)
 
 	// Get the first card
 	var card *Card
-	if len(srsDueCards) == 0 {
+	if len(srsDueItems) == 0 {
 		srsData := SrsData{
 			DueCount:            0,
 			LearningCount:       0,
 			Card:                nil,
 			MeaningMnemonicHtml: template.HTML(""),
 			ReadingMnemonicHtml: template.HTML(""),
+			HeldBack:            heldBack,
 		}
 		return srsData
 	}
 
-	card = srsDueCards[0]
+	card = srsDueItems[0].Card
 	var srsData SrsData
 
 	var sentenceHtml SentenceHtml
@@ -85,7 +109,11 @@ func (cd *CardData) GetNextSrsCard() SrsData {
 		ta := TextAnalysis{
 			Text: str,
 		}
-		ta.Analyse(cd)
+		// The sentence is still shown without its tokens if it can't be analysed
+		err := ta.Analyse(cd)
+		if err != nil {
+			log.Printf("Error analysing the sentence for card %d: %s", card.ID, err)
+		}
 
 		// Find the unprintable characters and replace the tokens that are in between them with the grammar tags
 		for i, token := range ta.Tokens {
@@ -100,130 +128,80 @@ func (cd *CardData) GetNextSrsCard() SrsData {
 	}
 	// Create SRS data
 	srsData.DueCount = l
-	srsData.LearningCount = len(learningCards)
+	srsData.LearningCount = len(learningItems)
 	srsData.Card = card
+	srsData.Facet = srsDueItems[0].Facet
+	srsData.Review = card.Review(srsData.Facet)
 	srsData.MeaningMnemonicHtml = template.HTML(customHtmlTagsToSpan(card.MeaningMnemonic))
 	srsData.ReadingMnemonicHtml = template.HTML(customHtmlTagsToSpan(card.ReadingMnemonic))
 	srsData.SentenceHtml = sentenceHtml
+	srsData.HeldBack = heldBack
 
 	return srsData
 }
 
+// Answer correctly with the default scheduler, now
 func (c *Card) CorrectAnswer() {
-	// Check the next review date is in the past, otherwise this is a mistaken endpoint hit.
-	t, err := time.Parse(time.RFC3339, c.NextReviewDate)
-	if err != nil {
-		log.Printf("Card %d has an invalid NextReviewDate: %s", c.ID, c.NextReviewDate)
-		panic(err)
-	}
-	if time.Now().Before(t) {
-		log.Printf("Card %d was reviewed too early. Next review date is %s", c.ID, c.NextReviewDate)
-		return
-	}
+	c.CorrectAnswerWith(DoublingScheduler{}, time.Now())
+}
 
-	c.ProcessCorrectAnswer()
+func (c *Card) CorrectAnswerWith(s Scheduler, now time.Time) bool {
+	return c.AnswerWith(s, Good, now)
 }
 
-func (c *Card) ProcessCorrectAnswer() {
-	if c.LearningStage == Learning { // Learning stage
-		c.LearningInterval *= 2
-
-		// If the LearningInterval is more than 24 hours, then the card has graduated to the learned
-		if c.LearningInterval >= 24 {
-			c.LearningStage = Learned
-			// Set the Interval to the max of LearningInterval and Interval
-			// This accounts for cards that have been learned before, but are now being learned again due to forgetting the answer.
-			if c.LearningInterval > c.Interval {
-				c.Interval = c.LearningInterval
-			} else {
-				c.LearningInterval = c.Interval
-			}
-			// Then set the LearningInterval to 0, as it is no longer needed.
-			c.LearningInterval = 0
-		}
-	} else if c.LearningStage == Learned { // Learned stage
-		c.Interval *= 2
+// Answer incorrectly with the default scheduler, now
+func (c *Card) IncorrectAnswer() {
+	c.IncorrectAnswerWith(DoublingScheduler{}, time.Now())
+}
 
-		// If the Interval is more than 365 days (8760 hours), then the card has graduated to the burned stage and will no longer be reviewed.
-		if c.Interval >= 8760 {
-			c.LearningStage = 4
-		}
-	} else if c.LearningStage == UpNext { // Up next stage
-		// If the card is in the up next stage, then it is being reviewed for the first time.
-		// Set the LearningStage to 2, and set the LearningInterval to 3 hours.
-		c.LearningStage = Learning
-		c.LearningInterval = 3
+func (c *Card) IncorrectAnswerWith(s Scheduler, now time.Time) bool {
+	return c.AnswerWith(s, Again, now)
+}
+
+// Answer the card at the time now.
+// Returns false if the answer was ignored because the card isn't due yet
+func (c *Card) AnswerWith(s Scheduler, g Grade, now time.Time) bool {
+	if !c.IsReviewable(now) {
+		return false
 	}
 
-	c.IncrementReviewCount()
-	c.IncrementCorrectAnswerCount()
-	c.SetNextReviewDate()
+	prevStage := c.LearningStage
+	s.ProcessAnswer(c, g, now)
+	c.countLapse(prevStage, g)
+	return true
 }
 
-func (c *Card) IncorrectAnswer() {
+func (c *Card) IsReviewable(now time.Time) bool {
 	// Check the next review date is in the past, otherwise this is a mistaken endpoint hit.
+	// Burned cards and cards that haven't been learned yet have no review date, so can't be reviewed.
 	t, err := time.Parse(time.RFC3339, c.NextReviewDate)
 	if err != nil {
-		log.Printf("Card %d has an invalid NextReviewDate: %s", c.ID, c.NextReviewDate)
-		panic(err)
+		log.Printf("Card %d has no valid NextReviewDate: %q", c.ID, c.NextReviewDate)
+		return false
 	}
-	if time.Now().Before(t) {
+	if now.Before(t) {
 		log.Printf("Card %d was reviewed too early. Next review date is %s", c.ID, c.NextReviewDate)
-		return
+		return false
 	}
 
-	c.ProcessIncorrectAnswer()
-}
-
-func (c *Card) ProcessIncorrectAnswer() {
-	if c.LearningStage == Learning { // Learning stage
-		// Only affect the LearningInterval.
-		// The Interval is not affected, to preserve progress.
-		c.LearningInterval /= 2
-
-		// LearniingInterval cannot be less than 3 hours.
-		if c.LearningInterval < 3 {
-			c.LearningInterval = 3
-		}
-		c.IncrementReviewCount()
-		c.SetNextFailedReviewDate()
-	} else if c.LearningStage == Learned { // Learned stage
-		c.Interval /= 2
-
-		// Card gets downgraded to the learning stage
-		c.LearningStage = Learning
-		c.LearningInterval = 3 // Initial LearningInterval is 3 hours
-
-		c.IncrementReviewCount()
-		c.SetNextFailedReviewDate()
-	} else if c.LearningStage == UpNext { // Up next stage
-		// If the card is in the up next stage, then it is being reviewed for the first time.
-		// If the answer is incorrect, then the card is rescheduled for the NextReviewDate + 10 minutes.
-		// Since the card's NextReviewDate is initialised to 1970-01-01, this will result in the card being reviewed immediately after the current queue of up next cards are done.
-		nrd, err := time.Parse(time.RFC3339, c.NextReviewDate)
-		if err != nil {
-			log.Printf("Card %d has an invalid NextReviewDate: %s", c.ID, c.NextReviewDate)
-			panic(err)
-		}
-		c.NextReviewDate = nrd.Add(10 * time.Minute).Format(time.RFC3339)
-	}
+	return true
 }
 
-func (c *Card) SetNextReviewDate() {
-	// Set the NextReviewDate to the current date + the Interval rounded to the hour.
+func (c *Card) SetNextReviewDate(now time.Time) {
+	// Set the NextReviewDate to now + the Interval rounded to the hour.
 	// If the card is in the learning stage, use the LearningInterval instead.
 	// Burned cards will not be reviewed.
 	if c.LearningStage == 2 {
-		c.NextReviewDate = time.Now().Add(time.Duration(c.LearningInterval) * time.Hour).Round(time.Hour).Format(time.RFC3339)
+		c.NextReviewDate = now.Add(time.Duration(c.LearningInterval) * time.Hour).Round(time.Hour).Format(time.RFC3339)
 	} else if c.LearningStage == 4 {
 		c.NextReviewDate = ""
 	} else {
-		c.NextReviewDate = time.Now().Add(time.Duration(c.Interval) * time.Hour).Round(time.Hour).Format(time.RFC3339)
+		c.NextReviewDate = now.Add(time.Duration(c.Interval) * time.Hour).Round(time.Hour).Format(time.RFC3339)
 	}
 }
 
-func (c *Card) SetNextFailedReviewDate() {
-	// Set the NextReviewDate to the current date + 10 minutes.
+func (c *Card) SetNextFailedReviewDate(now time.Time) {
+	// Set the NextReviewDate to now + 10 minutes.
 	// User is forced to keep reviewing the card until they get it right.
-	c.NextReviewDate = time.Now().Add(time.Duration(10) * time.Minute).Round(time.Minute).Format(time.RFC3339)
+	c.NextReviewDate = now.Add(time.Duration(10) * time.Minute).Round(time.Minute).Format(time.RFC3339)
 }
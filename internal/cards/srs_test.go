@@ -728,3 +728,127 @@ func TestUpNextOrder(t *testing.T) {
 		t.Errorf("Incorrect card. Expected %d, got %d", 5, unc[0].ID)
 	}
 }
+
+func TestHardLearnedToLearned(t *testing.T) {
+	c := Card{
+		ID:               1,
+		Interval:         48, // 2 days
+		LearningInterval: 0,
+		LearningStage:    Learned,
+		NextReviewDate:   "2020-01-01T00:00:00Z", // Any date in the past
+	}
+
+	c.AnswerWith(DoublingScheduler{}, Hard, time.Now())
+	// Hard grows the interval by 1.5x instead of doubling
+	if c.Interval != 72 {
+		t.Errorf("Incorrect interval. Expected 72, got %d", c.Interval)
+	}
+	if c.LearningStage != Learned {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learned, c.LearningStage)
+	}
+	if c.TotalTimesReviewed != 1 || c.TotalTimesCorrect != 1 {
+		t.Errorf("Incorrect review counts. Expected 1 / 1, got %d / %d", c.TotalTimesCorrect, c.TotalTimesReviewed)
+	}
+}
+
+func TestEasyLearnedToLearned(t *testing.T) {
+	c := Card{
+		ID:               1,
+		Interval:         48, // 2 days
+		LearningInterval: 0,
+		LearningStage:    Learned,
+		NextReviewDate:   "2020-01-01T00:00:00Z", // Any date in the past
+	}
+
+	c.AnswerWith(DoublingScheduler{}, Easy, time.Now())
+	// Easy grows the interval by 3x instead of doubling
+	if c.Interval != 144 {
+		t.Errorf("Incorrect interval. Expected 144, got %d", c.Interval)
+	}
+	ExpectedNextReviewDate := time.Now().Add(6 * 24 * time.Hour).Round(time.Hour).Format(time.RFC3339)
+	if c.NextReviewDate != ExpectedNextReviewDate {
+		t.Errorf("Incorrect next review date. Expected %s, got %s", ExpectedNextReviewDate, c.NextReviewDate)
+	}
+}
+
+func TestHardLearningToLearning(t *testing.T) {
+	c := Card{
+		ID:               1,
+		Interval:         0,
+		LearningInterval: 4,
+		LearningStage:    Learning,
+		NextReviewDate:   "2020-01-01T00:00:00Z", // Any date in the past
+	}
+
+	c.AnswerWith(DoublingScheduler{}, Hard, time.Now())
+	if c.LearningInterval != 6 {
+		t.Errorf("Incorrect learning interval. Expected 6, got %d", c.LearningInterval)
+	}
+	if c.LearningStage != Learning {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learning, c.LearningStage)
+	}
+}
+
+func TestEasyLearningToLearned(t *testing.T) {
+	c := Card{
+		ID:               1,
+		Interval:         0,
+		LearningInterval: 9,
+		LearningStage:    Learning,
+		NextReviewDate:   "2020-01-01T00:00:00Z", // Any date in the past
+	}
+
+	c.AnswerWith(DoublingScheduler{}, Easy, time.Now())
+	if c.LearningStage != Learned {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learned, c.LearningStage)
+	}
+	if c.Interval != 27 {
+		t.Errorf("Incorrect interval. Expected 27, got %d", c.Interval)
+	}
+	if c.LearningInterval != 0 {
+		t.Errorf("Incorrect learning interval. Expected 0, got %d", c.LearningInterval)
+	}
+}
+
+func TestEasyUpNextToLearned(t *testing.T) {
+	c := Card{
+		ID:             1,
+		LearningStage:  UpNext,
+		NextReviewDate: "1970-01-01T00:00:00Z", // 0 unix time
+	}
+
+	// Easy up next cards skip the learning stage
+	c.AnswerWith(DoublingScheduler{}, Easy, time.Now())
+	if c.LearningStage != Learned {
+		t.Errorf("Incorrect learning stage. Expected %d, got %d", Learned, c.LearningStage)
+	}
+	if c.Interval != 24 {
+		t.Errorf("Incorrect interval. Expected 24, got %d", c.Interval)
+	}
+
+	// The card should stay learned when the card data is updated
+	cd := CreateCardDataFromSlice([]*Card{&c})
+	cd.UpdateCardData()
+	if c.LearningStage != Learned {
+		t.Errorf("Incorrect learning stage after update. Expected %d, got %d", Learned, c.LearningStage)
+	}
+}
+
+func TestAnswerTooEarly(t *testing.T) {
+	nextReviewDate := time.Now().Add(time.Hour).Format(time.RFC3339)
+	c := Card{
+		ID:             1,
+		Interval:       48,
+		LearningStage:  Learned,
+		NextReviewDate: nextReviewDate,
+	}
+
+	for _, g := range Grades {
+		if c.AnswerWith(DoublingScheduler{}, g, time.Now()) {
+			t.Errorf("Expected %s answer to be ignored for a card that isn't due", g)
+		}
+	}
+	if c.Interval != 48 || c.NextReviewDate != nextReviewDate || c.TotalTimesReviewed != 0 {
+		t.Errorf("Card should not have changed. Got interval %d, next review date %s", c.Interval, c.NextReviewDate)
+	}
+}
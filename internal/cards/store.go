@@ -0,0 +1,367 @@
+package cards
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// CardData is shared between concurrent HTTP handlers.
+// All changes to cards go through Update, which holds the write lock for the whole mutation and save.
+// Pages are rendered from a Snapshot, so templates never see a card halfway through being changed.
+
+var ErrCardNotFound = errors.New("card not found")
+var ErrReadOnly = errors.New("card data is a read-only snapshot")
+var ErrNotQueueable = errors.New("card is not queueable")
+var ErrNoSuchFacet = errors.New("card does not have this facet")
+var ErrNotReviewable = errors.New("card is not being reviewed")
+
+// Update runs mutate while holding the write lock.
+// If mutate succeeds, the learning stages are re-evaluated and the changed cards are journaled and saved.
+func (cd *CardData) Update(mutate func() error) error {
+	return cd.update(mutate, nil)
+}
+
+// update is Update, also calling updated, if set, once the learning stages have been re-evaluated
+func (cd *CardData) update(mutate func() error, updated func()) error {
+	if cd.readOnly {
+		return ErrReadOnly
+	}
+
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	err := mutate()
+	if err != nil {
+		return err
+	}
+
+	// Mutations may replace cards rather than change them, so make sure the queue points at the current ones
+	cd.relinkUpNext()
+	cd.UpdateCardData()
+	if updated != nil {
+		updated()
+	}
+	return cd.commit()
+}
+
+// Snapshot returns a read-only deep copy of the cards and up next queue.
+// The dictionary is shared rather than copied, as it is never modified after loading.
+func (cd *CardData) Snapshot() *CardData {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+	return cd.snapshot()
+}
+
+// Caller must hold the lock
+func (cd *CardData) snapshot() *CardData {
+	s := &CardData{
+		CardsFile:  cd.CardsFile,
+		DataDir:    cd.DataDir,
+		ProfileDir: cd.ProfileDir,
+		Profile:    cd.Profile,
+		BackupDir:  cd.BackupDir,
+		StaticDir:  cd.StaticDir,
+		Dev:        cd.Dev,
+		Scheduler:  cd.Scheduler,
+		FuncMap:    cd.FuncMap,
+		templates:  cd.templates,
+
+		DictionaryFile:    cd.DictionaryFile,
+		KanjiFrequencyDir: cd.KanjiFrequencyDir,
+
+		BackupRetention: cd.BackupRetention,
+		DayBoundary:     cd.DayBoundary,
+		Clock:           cd.Clock,
+		today:           cd.todayLog(),
+
+		Dictionary:                   cd.Dictionary,
+		DictionaryEntities:           cd.DictionaryEntities,
+		DictionaryMap:                cd.DictionaryMap,
+		DictionaryKanjiMap:           cd.DictionaryKanjiMap,
+		DictionaryReadingMap:         cd.DictionaryReadingMap,
+		DictionaryNonKanjiReadingMap: cd.DictionaryNonKanjiReadingMap,
+		DictionaryMeaningMap:         cd.DictionaryMeaningMap,
+
+		readOnly: true,
+	}
+
+	s.Cards = make(map[int]*Card, len(cd.Cards))
+	for id, c := range cd.Cards {
+		s.Cards[id] = c.Copy()
+	}
+	for _, c := range cd.UpNext {
+		s.UpNext = append(s.UpNext, s.Cards[c.ID])
+	}
+	s.Vacations = append([]VacationPeriod(nil), cd.Vacations...)
+
+	return s
+}
+
+// Caller must hold the lock
+func (cd *CardData) getCardOrError(id int) (*Card, error) {
+	c, ok := cd.Cards[id]
+	if !ok {
+		return nil, fmt.Errorf("card %d: %w", id, ErrCardNotFound)
+	}
+	return c, nil
+}
+
+type AnswerResult struct {
+	Card          *Card // Copy of the card after the answer
+	Facet         Facet
+	Answered      bool // False if the facet wasn't due, or you're on vacation, so the answer was ignored
+	PreviousStage LearningStage
+	NewStage      LearningStage
+	Leech         bool // The facet has lapsed too many times in a row, and the leech action has been taken
+}
+
+// Answer one facet of a card
+func (cd *CardData) AnswerCard(id int, f Facet, g Grade, responseTime int) (AnswerResult, error) {
+	return cd.AnswerCardInSession("", id, f, g, responseTime)
+}
+
+// Answer one facet of a card, so that it can be undone with UndoAnswer by the same client
+func (cd *CardData) AnswerCardInSession(client string, id int, f Facet, g Grade, responseTime int) (AnswerResult, error) {
+	result := AnswerResult{Facet: f}
+	var before map[int]CardProgress
+	upNextPos := -1
+	err := cd.update(func() error {
+		c, err := cd.getCardOrError(id)
+		if err != nil {
+			return err
+		}
+		if !c.HasFacet(f) {
+			return fmt.Errorf("card %d, %s: %w", id, f, ErrNoSuchFacet)
+		}
+		if !c.Review(f).isScheduled() {
+			return fmt.Errorf("card %d, %s: %w", id, f, ErrNotReviewable)
+		}
+		// Nothing is due on vacation
+		if cd.CurrentVacation() != nil {
+			return nil
+		}
+
+		before = cd.answerProgress(id)
+		upNextPos = cd.upNextIndex(id)
+		prev := c.Review(f)
+		result.PreviousStage = prev.LearningStage
+		result.Answered = c.AnswerFacetWith(cd.GetScheduler(), f, g, cd.Now())
+
+		if result.Answered {
+			cd.fuzzReviewDate(c, f)
+			err = cd.LogReview(c, f, g, prev.LearningStage, prev.CurrentInterval(), responseTime)
+			if err != nil {
+				log.Printf("Error writing review log: %s", err)
+			}
+			result.Leech = cd.handleLeech(c, f)
+		}
+		return nil
+	}, func() {
+		// Dependent cards are only unlocked once the learning stages have been updated
+		if result.Answered {
+			cd.pushUndo(client, undoEntry{CardID: id, Facet: f, Grade: g, UpNextPos: upNextPos}, before)
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// Learning stages are only final once the card data has been updated
+	cd.mu.RLock()
+	result.Card = cd.Cards[id].Copy()
+	cd.mu.RUnlock()
+	result.NewStage = result.Card.Review(f).LearningStage
+
+	return result, nil
+}
+
+// Check a typed answer to one facet of a card. Nothing changes until the answer is graded with AnswerCard.
+func (cd *CardData) CheckAnswer(id int, f Facet, answer string) (AnswerCheck, error) {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+
+	c, err := cd.getCardOrError(id)
+	if err != nil {
+		return AnswerCheck{}, err
+	}
+	if !c.HasFacet(f) {
+		return AnswerCheck{}, fmt.Errorf("card %d, %s: %w", id, f, ErrNoSuchFacet)
+	}
+	return c.CheckAnswer(f, answer), nil
+}
+
+// Replace a card, e.g. after it has been edited.
+// Other profiles get the new content, but keep their own progress on the card.
+func (cd *CardData) SaveCard(id int, c *Card) error {
+	// c belongs to cd once it is saved, so other profiles copy from their own copy
+	content := c.Content()
+	return cd.updateContent(func(p *CardData) error {
+		if p == cd {
+			p.Cards[id] = c
+		} else {
+			p.putContent(id, content)
+		}
+		return nil
+	})
+}
+
+// Add a new card with the next free ID. Returns the ID.
+func (cd *CardData) AddNewCard(c *Card) (int, error) {
+	content := c.Content()
+	err := cd.updateContent(func(p *CardData) error {
+		if p == cd {
+			c.ID = cd.GetNewCardId()
+			cd.AddCard(c)
+		} else {
+			p.putContent(c.ID, content)
+		}
+		return nil
+	})
+	return c.ID, err
+}
+
+func (cd *CardData) RemoveCard(id int) error {
+	return cd.updateContent(func(p *CardData) error {
+		_, err := p.getCardOrError(id)
+		if err != nil {
+			return err
+		}
+		err = p.DeleteCard(id)
+		if err != nil {
+			return err
+		}
+		p.RemoveUpNextCard(id)
+		return nil
+	})
+}
+
+func (cd *CardData) SuspendCard(id int) error {
+	return cd.Update(func() error {
+		c, err := cd.getCardOrError(id)
+		if err != nil {
+			return err
+		}
+		c.TagSuspended()
+		cd.RemoveUpNextCard(id) // Remove the card from the queue if it's there
+		return nil
+	})
+}
+
+func (cd *CardData) QueueCard(id int) error {
+	return cd.Update(func() error {
+		c, err := cd.getCardOrError(id)
+		if err != nil {
+			return err
+		}
+		if !c.IsQueueable() {
+			return fmt.Errorf("card %d: %w", id, ErrNotQueueable)
+		}
+		c.SetQueuedToLearn(cd)
+		return nil
+	})
+}
+
+// Replace your synonyms and blocked answers for a card
+func (cd *CardData) SetUserAnswers(id int, synonyms []string, blocked []string) error {
+	return cd.Update(func() error {
+		c, err := cd.getCardOrError(id)
+		if err != nil {
+			return err
+		}
+		c.UserSynonyms = cleanAnswers(synonyms)
+		c.BlockedAnswers = cleanAnswers(blocked)
+		return nil
+	})
+}
+
+func (cd *CardData) SetCardCharacterImage(id int, filename string) error {
+	return cd.updateContent(func(p *CardData) error {
+		c, err := p.getCardOrError(id)
+		if err != nil {
+			return err
+		}
+		c.CharacterImage = filename
+		return nil
+	})
+}
+
+// The up next queue is only held in memory, so these don't need to save.
+
+func (cd *CardData) QueueUpNextCards(n int) error {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	return cd.AddUpNextCards(n)
+}
+
+func (cd *CardData) AddToUpNextQueue(id int) error {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	c, err := cd.getCardOrError(id)
+	if err != nil {
+		return err
+	}
+	cd.UpNext = append(cd.UpNext, c)
+	return nil
+}
+
+// Pick the next card to review, along with the snapshot it should be rendered from.
+// Picking a card rotates the up next queue, so this needs the write lock.
+func (cd *CardData) NextSrsCard() (SrsData, *CardData) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+
+	s := cd.snapshot()
+	srsData := s.GetNextSrsCard()
+
+	// Keep the snapshot's rotation of the up next queue
+	cd.UpNext = s.UpNext
+	cd.relinkUpNext()
+
+	return srsData, s
+}
+
+// Point the up next queue at the cards in cd.Cards, dropping any that no longer exist
+// Caller must hold the lock
+func (cd *CardData) relinkUpNext() {
+	var upNext []*Card
+	for _, c := range cd.UpNext {
+		if current, ok := cd.Cards[c.ID]; ok {
+			upNext = append(upNext, current)
+		}
+	}
+	cd.UpNext = upNext
+}
+
+// Copy returns a deep copy of the card
+func (c *Card) Copy() *Card {
+	n := *c
+
+	n.CharactersAlternateWritings = copyStrings(c.CharactersAlternateWritings)
+	n.Meanings = append([]Meaning(nil), c.Meanings...)
+	n.Readings = append([]Reading(nil), c.Readings...)
+	n.PartsOfSpeech = copyStrings(c.PartsOfSpeech)
+	n.ComponentSubjectIDs = append([]int(nil), c.ComponentSubjectIDs...)
+	n.AmalgamationSubjectIDs = append([]int(nil), c.AmalgamationSubjectIDs...)
+	n.Audio = append([]Audio(nil), c.Audio...)
+	n.Sentences = append([]Sentence(nil), c.Sentences...)
+	n.Tags = copyStrings(c.Tags)
+	n.ReadingReview = copyFacetReview(c.ReadingReview)
+	n.UserSynonyms = copyStrings(c.UserSynonyms)
+	n.BlockedAnswers = copyStrings(c.BlockedAnswers)
+
+	return &n
+}
+
+func copyFacetReview(r *FacetReview) *FacetReview {
+	if r == nil {
+		return nil
+	}
+	n := *r
+	return &n
+}
+
+func copyStrings(s []string) []string {
+	return append([]string(nil), s...)
+}
@@ -0,0 +1,103 @@
+package cards
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func createStoreCardData(t *testing.T, n int) *CardData {
+	var cs []*Card
+	for i := 1; i <= n; i++ {
+		c := CreateCard(i, 48, 0, "2020-01-01T00:00:00Z")
+		c.LearningStage = Learned
+		cs = append(cs, c)
+	}
+	cd := CreateCardDataFromSlice(cs)
+	cd.DataDir = t.TempDir()
+	cd.CardsFile = filepath.Join(cd.DataDir, "cards.json")
+	return cd
+}
+
+func TestSnapshotIsIndependent(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	cd.Cards[1].Tags = []string{"tag"}
+
+	s := cd.Snapshot()
+	s.Cards[1].Interval = 1000
+	s.Cards[1].Tags[0] = "changed"
+
+	if cd.Cards[1].Interval != 48 {
+		t.Errorf("Expected interval 48, got %d", cd.Cards[1].Interval)
+	}
+	if cd.Cards[1].Tags[0] != "tag" {
+		t.Errorf("Expected tag to be unchanged, got %s", cd.Cards[1].Tags[0])
+	}
+
+	err := s.SaveCard(1, s.Cards[1])
+	if !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestAnswerCardNotFound(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+
+	_, err := cd.AnswerCard(2, MeaningFacet, Good, 0)
+	if !errors.Is(err, ErrCardNotFound) {
+		t.Errorf("Expected ErrCardNotFound, got %v", err)
+	}
+}
+
+// Run with -race to check that handlers can't corrupt the card data
+func TestConcurrentUpdates(t *testing.T) {
+	n := 20
+	cd := createStoreCardData(t, n)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(3)
+
+		// Answer each card twice at the same time. Only one answer should count, as the card is no longer due after the first.
+		for j := 0; j < 2; j++ {
+			go func(id int) {
+				defer wg.Done()
+				_, err := cd.AnswerCard(id, MeaningFacet, Good, 0)
+				if err != nil {
+					t.Errorf("Error answering card %d: %s", id, err)
+				}
+			}(i)
+		}
+
+		// Read while the answers are being processed
+		go func() {
+			defer wg.Done()
+			s := cd.Snapshot()
+			for _, c := range s.ToList() {
+				_ = c.GetLearningStageString()
+			}
+			srsData, _ := cd.NextSrsCard()
+			_ = srsData.DueCount
+		}()
+	}
+	wg.Wait()
+
+	for i := 1; i <= n; i++ {
+		c := cd.Cards[i]
+		if c.TotalTimesReviewed != 1 {
+			t.Errorf("Card %d: expected 1 review, got %d", i, c.TotalTimesReviewed)
+		}
+		if c.Interval != 96 {
+			t.Errorf("Card %d: expected interval 96, got %d", i, c.Interval)
+		}
+	}
+
+	entries, err := cd.GetReviewLog()
+	if err != nil {
+		t.Fatalf("Error reading review log: %s", err)
+	}
+	if len(entries) != n {
+		t.Errorf("Expected %d review log entries, got %d", n, len(entries))
+	}
+}
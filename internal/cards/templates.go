@@ -0,0 +1,109 @@
+package cards
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+
+	"moekyuniversity/static"
+)
+
+// Templates and static files are embedded in the binary, and the templates are parsed once by LoadTemplates.
+// In dev mode both are read from StaticDir on every request instead, so edits show up on reload.
+
+// The static files: StaticDir in dev mode, otherwise the embedded files
+func (cd *CardData) StaticFiles() fs.FS {
+	if cd.Dev {
+		return os.DirFS(cd.StaticDir)
+	}
+	return static.Files
+}
+
+// Parsed templates by page name, shared by every profile
+type templateSet map[string]*template.Template
+
+// Functions that depend on the request. These are placeholders for parsing, and are replaced when rendering.
+func requestFuncs(cd *CardData, r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"profile": func() string {
+			return cd.Profile
+		},
+		"csrftoken": func() string {
+			if r == nil {
+				return ""
+			}
+			return CSRFToken(r)
+		},
+	}
+}
+
+// Parse a page together with the main template it fills in
+func (cd *CardData) parseTemplate(name string) (*template.Template, error) {
+	return template.New("templatemain.html").Funcs(cd.FuncMap).Funcs(requestFuncs(cd, nil)).
+		ParseFS(cd.StaticFiles(), "html/templatemain.html", path.Join("html", name))
+}
+
+// Parse every page, so a broken template is found at startup rather than when the page is visited.
+// Does nothing in dev mode. Call after SetupFuncMap.
+func (cd *CardData) LoadTemplates() error {
+	if cd.Dev {
+		return nil
+	}
+	names, err := fs.Glob(cd.StaticFiles(), "html/*.html")
+	if err != nil {
+		return err
+	}
+	ts := templateSet{}
+	for _, name := range names {
+		name = path.Base(name)
+		if name == "templatemain.html" {
+			continue
+		}
+		t, err := cd.parseTemplate(name)
+		if err != nil {
+			return err
+		}
+		ts[name] = t
+	}
+	cd.templates = ts
+	return nil
+}
+
+// The template for a page, ready to execute for the request
+func (cd *CardData) pageTemplate(r *http.Request, name string) (*template.Template, error) {
+	t, ok := cd.templates[name]
+	if !ok {
+		// Dev mode, or a page that doesn't exist, which parsing reports
+		var err error
+		t, err = cd.parseTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	t, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.Funcs(requestFuncs(cd, r)), nil
+}
+
+// Render a page into a buffer first, so nothing is written if the template fails
+func (cd *CardData) renderTemplate(w http.ResponseWriter, r *http.Request, status int, templateName string, data interface{}) error {
+	t, err := cd.pageTemplate(r, templateName)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+	return nil
+}
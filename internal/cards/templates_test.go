@@ -0,0 +1,68 @@
+package cards
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedStaticFiles(t *testing.T) {
+	cd, h := createTestRouter(t, 1)
+	// Nothing is read from the static directory unless in dev mode
+	cd.StaticDir = "nowhere"
+
+	if _, ok := cd.templates["card.html"]; !ok {
+		t.Errorf("Expected the card page to be parsed by LoadTemplates")
+	}
+	if _, ok := cd.templates["templatemain.html"]; ok {
+		t.Errorf("Expected templatemain.html not to be a page of its own")
+	}
+
+	for _, path := range []string{"/", "/stylesheet.css", "/favicon.ico", "/img/icon.png", "/static/js/jsoneditor/jsoneditor.min.js"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("Expected %s to be served, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestDevModeReloadsTemplates(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	cd.StaticDir = t.TempDir()
+	cd.Dev = true
+	cd.SetupFuncMap()
+	err := cd.LoadTemplates()
+	if err != nil || cd.templates != nil {
+		t.Fatalf("Expected templates not to be loaded in dev mode, got %v", err)
+	}
+
+	htmlDir := filepath.Join(cd.StaticDir, "html")
+	err = os.MkdirAll(htmlDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePage := func(contents string) {
+		err := ioutil.WriteFile(filepath.Join(htmlDir, "templatemain.html"), []byte(`<p>{{ template "content" . }}</p>`), 0644)
+		if err == nil {
+			err = ioutil.WriteFile(filepath.Join(htmlDir, "index.html"), []byte(`{{ define "content" }}`+contents+`{{ end }}`), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewRouter(cd, singleProfile(cd))
+	for _, contents := range []string{"before", "after"} {
+		writePage(contents)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if !strings.Contains(w.Body.String(), "<p>"+contents+"</p>") {
+			t.Errorf("Expected the page to say %s, got %d %s", contents, w.Code, w.Body.String())
+		}
+	}
+}
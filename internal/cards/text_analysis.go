@@ -2,10 +2,16 @@ package cards
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/ikawaha/kagome-dict/ipa"
 	"github.com/ikawaha/kagome/v2/tokenizer"
 )
@@ -31,16 +37,98 @@ type Token struct {
 	Card                *Card             // The card that matches this token
 }
 
-func (ta *TextAnalysis) Save(filepath string) {
+func (ta *TextAnalysis) Save(filepath string) error {
 	taJson, err := json.Marshal(ta)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	err = ioutil.WriteFile(filepath, taJson, 0644)
+	return ioutil.WriteFile(filepath, taJson, 0644)
+}
+
+var ErrTextAnalysisNotFound = errors.New("text analysis not found")
+
+// All saved text analyses, sorted by name. The texts are not analysed.
+func (cd *CardData) ListTextAnalyses() ([]TextAnalysis, error) {
+	files, err := ioutil.ReadDir(cd.TextAnalysisDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var taList []TextAnalysis
+	for _, f := range files {
+		ta, err := readTextAnalysis(filepath.Join(cd.TextAnalysisDir(), f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		taList = append(taList, ta)
+	}
+
+	// Sort the list by Name
+	sort.Slice(taList, func(i, j int) bool {
+		return taList[i].Name < taList[j].Name
+	})
+	return taList, nil
+}
+
+// Read a saved text analysis. The text is not analysed.
+func (cd *CardData) GetTextAnalysis(id string) (TextAnalysis, error) {
+	path, err := cd.textAnalysisFile(id)
+	if err != nil {
+		return TextAnalysis{}, err
+	}
+	return readTextAnalysis(path)
+}
+
+func (cd *CardData) NewTextAnalysis(name string, text string) (TextAnalysis, error) {
+	ta := TextAnalysis{
+		ID:   uuid.New().String(),
+		Name: name,
+		Text: text,
+	}
+
+	err := os.MkdirAll(cd.TextAnalysisDir(), 0755)
+	if err != nil {
+		return ta, err
+	}
+	err = ta.Save(filepath.Join(cd.TextAnalysisDir(), ta.ID+".json"))
+	return ta, err
+}
+
+func (cd *CardData) DeleteTextAnalysis(id string) error {
+	path, err := cd.textAnalysisFile(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("text analysis %s: %w", id, ErrTextAnalysisNotFound)
+	}
+	return err
+}
+
+// IDs come from URLs, so only accept UUIDs to keep them inside the text analysis directory
+func (cd *CardData) textAnalysisFile(id string) (string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", fmt.Errorf("text analysis %s: %w", id, ErrTextAnalysisNotFound)
+	}
+	return filepath.Join(cd.TextAnalysisDir(), id+".json"), nil
+}
+
+func readTextAnalysis(path string) (TextAnalysis, error) {
+	var ta TextAnalysis
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ta, fmt.Errorf("%s: %w", filepath.Base(path), ErrTextAnalysisNotFound)
+	}
 	if err != nil {
-		panic(err)
+		return ta, err
 	}
+	err = json.Unmarshal(data, &ta)
+	return ta, err
 }
 
 func ConvertToken(token tokenizer.Token) Token {
@@ -60,10 +148,10 @@ func ConvertToken(token tokenizer.Token) Token {
 	return to
 }
 
-func (ta *TextAnalysis) Analyse(cd *CardData) {
+func (ta *TextAnalysis) Analyse(cd *CardData) error {
 	t, err := tokenizer.New(ipa.Dict(), tokenizer.OmitBosEos())
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	log.Printf("Analyzing text: %s", ta.Name)
@@ -97,6 +185,7 @@ func (ta *TextAnalysis) Analyse(cd *CardData) {
 	log.Printf("Processed %d tokens in %s", len(tokens), endTime.Sub(startTime))
 	// debugJsonPrint(ts)
 	ta.Tokens = ts
+	return nil
 }
 
 func IsGrammar(to Token) bool {
@@ -0,0 +1,211 @@
+package cards
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Answers can be undone, in case of a mis-click.
+// Each answer remembers the progress from before and after it of every card it changed, including cards that were
+// unlocked when the learning stages were updated, and where the card was in the up next queue.
+// An answer is only undone if none of those cards have changed since.
+// Each client has its own short undo stack, so you only undo your own answers. See clientID.
+// The stacks are only held in memory, so answers can't be undone after a restart.
+// A stack that hasn't been used for a day is dropped, so clients that never come back don't leave theirs behind.
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrUndoConflict = errors.New("card has changed since it was answered")
+
+const undoStackSize = 10
+const undoStackLifetime = 24 * time.Hour
+
+type undoStack struct {
+	entries  []undoEntry
+	lastUsed time.Time // Wall clock time of the last answer
+}
+
+func (s *undoStack) expired() bool {
+	return time.Since(s.lastUsed) > undoStackLifetime
+}
+
+type undoEntry struct {
+	CardID    int
+	Facet     Facet
+	Grade     Grade
+	Before    map[int]CardProgress // Progress before the answer of every card it changed
+	After     map[int]CardProgress // Progress after the answer of the same cards
+	UpNextPos int                  // Where the card was in the up next queue before the answer. -1 if it wasn't.
+}
+
+type UndoResult struct {
+	Card  *Card // Copy of the card after the undo
+	Facet Facet
+	Grade Grade // The answer that was undone
+}
+
+// The progress of the cards an answer to card id can change: the card itself,
+// and the cards it is a component of, which it can unlock or lock.
+// Caller must hold the lock
+func (cd *CardData) answerProgress(id int) map[int]CardProgress {
+	progress := map[int]CardProgress{id: cd.Cards[id].Progress()}
+	for _, c := range cd.Cards {
+		for _, component := range c.ComponentSubjectIDs {
+			if component == id {
+				progress[c.ID] = c.Progress()
+				break
+			}
+		}
+	}
+	return progress
+}
+
+// Caller must hold the lock
+func (cd *CardData) upNextIndex(id int) int {
+	for i, c := range cd.UpNext {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Remember an answer so it can be undone. before is the answerProgress from before the answer.
+// Caller must hold the lock, and the learning stages must have been updated since the answer.
+func (cd *CardData) pushUndo(client string, e undoEntry, before map[int]CardProgress) {
+	e.Before = make(map[int]CardProgress)
+	e.After = map[int]CardProgress{e.CardID: cd.Cards[e.CardID].Progress()}
+	for id, p := range before {
+		c, ok := cd.Cards[id]
+		if !ok {
+			continue
+		}
+		if after := c.Progress(); !reflect.DeepEqual(after, p) {
+			e.Before[id] = p
+			e.After[id] = after
+		}
+	}
+
+	if cd.undo == nil {
+		cd.undo = make(map[string]*undoStack)
+	}
+	for client, stack := range cd.undo {
+		if stack.expired() {
+			delete(cd.undo, client)
+		}
+	}
+	stack, ok := cd.undo[client]
+	if !ok {
+		stack = &undoStack{}
+		cd.undo[client] = stack
+	}
+	stack.entries = append(stack.entries, e)
+	if len(stack.entries) > undoStackSize {
+		stack.entries = stack.entries[len(stack.entries)-undoStackSize:]
+	}
+	stack.lastUsed = time.Now()
+}
+
+// The client's undo stack, or nil if it has none
+// Caller must hold the lock
+func (cd *CardData) undoStackFor(client string) *undoStack {
+	stack, ok := cd.undo[client]
+	if !ok || stack.expired() || len(stack.entries) == 0 {
+		return nil
+	}
+	return stack
+}
+
+// Undo the client's last answer, putting back every card it changed as it was
+func (cd *CardData) UndoAnswer(client string) (UndoResult, error) {
+	var result UndoResult
+	var id int
+	err := cd.Update(func() error {
+		stack := cd.undoStackFor(client)
+		if stack == nil {
+			return ErrNothingToUndo
+		}
+		// The answer can't be undone a second time, even if this one fails
+		e := stack.entries[len(stack.entries)-1]
+		stack.entries = stack.entries[:len(stack.entries)-1]
+
+		c, err := cd.getCardOrError(e.CardID)
+		if err != nil {
+			return err
+		}
+		// Undoing would wipe out anything that has happened to the cards since
+		for changedID, after := range e.After {
+			o, ok := cd.Cards[changedID]
+			if !ok || !reflect.DeepEqual(o.Progress(), after) {
+				return fmt.Errorf("card %d: %w", changedID, ErrUndoConflict)
+			}
+		}
+
+		prevStage := c.Review(e.Facet).LearningStage
+		prevInterval := c.Review(e.Facet).CurrentInterval()
+		for changedID, p := range e.Before {
+			if o, ok := cd.Cards[changedID]; ok {
+				o.SetProgress(p)
+			}
+		}
+		cd.RemoveUpNextCard(c.ID)
+		if e.UpNextPos >= 0 {
+			i := e.UpNextPos
+			if i > len(cd.UpNext) {
+				i = len(cd.UpNext)
+			}
+			cd.UpNext = append(cd.UpNext[:i], append([]*Card{c}, cd.UpNext[i:]...)...)
+		}
+
+		err = cd.LogUndo(c, e.Facet, e.Grade, prevStage, prevInterval)
+		if err != nil {
+			log.Printf("Error writing review log: %s", err)
+		}
+		id = e.CardID
+		result.Facet = e.Facet
+		result.Grade = e.Grade
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// Learning stages are only final once the card data has been updated
+	cd.mu.RLock()
+	result.Card = cd.Cards[id].Copy()
+	cd.mu.RUnlock()
+	return result, nil
+}
+
+func (cd *CardData) CanUndo(client string) bool {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+	return cd.undoStackFor(client) != nil
+}
+
+// Forget the undo stack of a client, e.g. when logging out
+func (cd *CardData) DropUndo(client string) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	delete(cd.undo, client)
+}
+
+// Record in the review log that an answer was undone. The stages and intervals are from before and after the undo.
+func (cd *CardData) LogUndo(c *Card, f Facet, g Grade, prevStage LearningStage, prevInterval int) error {
+	r := c.Review(f)
+	entry := ReviewLogEntry{
+		CardID:           c.ID,
+		Facet:            f,
+		Timestamp:        cd.Now().Format(time.RFC3339),
+		Grade:            g,
+		PreviousStage:    prevStage,
+		NewStage:         r.LearningStage,
+		PreviousInterval: prevInterval,
+		NewInterval:      r.CurrentInterval(),
+		Undo:             true,
+	}
+
+	return cd.appendReviewLog(entry)
+}
@@ -0,0 +1,200 @@
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Card data with card 1 up next, and card 2 waiting for it to be learned
+func createUndoCardData(t *testing.T) *CardData {
+	c := CreateCard(1, 0, 0, "1970-01-01T00:00:00Z")
+	c.QueuedToLearn = true
+	dependent := CreateCard(2, 0, 0, "")
+	dependent.ComponentSubjectIDs = []int{1}
+	other := CreateCard(3, 0, 0, "1970-01-01T00:00:00Z")
+	other.QueuedToLearn = true
+
+	cd := CreateCardDataFromSlice([]*Card{c, dependent, other})
+	cd.DataDir = t.TempDir()
+	cd.CardsFile = filepath.Join(cd.DataDir, "cards.json")
+	cd.UpdateCardData()
+	cd.UpNext = []*Card{other, c}
+	return cd
+}
+
+func TestUndoAnswer(t *testing.T) {
+	cd := createUndoCardData(t)
+	before := cd.Cards[1].Progress()
+
+	_, err := cd.AnswerCardInSession("a", 1, MeaningFacet, Easy, 0)
+	if err != nil {
+		t.Fatalf("Error answering card: %s", err)
+	}
+	if cd.Cards[1].LearningStage != Learned || cd.Cards[2].LearningStage != Available || len(cd.UpNext) != 1 {
+		t.Fatalf("Expected the card to be learned and unlock card 2, got stages %d and %d", cd.Cards[1].LearningStage, cd.Cards[2].LearningStage)
+	}
+
+	result, err := cd.UndoAnswer("a")
+	if err != nil {
+		t.Fatalf("Error undoing answer: %s", err)
+	}
+	if result.Card.ID != 1 || result.Grade != Easy {
+		t.Errorf("Expected the Easy answer to card 1 to be undone, got %s to card %d", result.Grade, result.Card.ID)
+	}
+	c := cd.Cards[1]
+	if c.LearningStage != UpNext || c.NextReviewDate != before.NextReviewDate || c.TotalTimesReviewed != 0 || c.Interval != 0 {
+		t.Errorf("Expected the card to be as it was, got stage %d, next review %s, reviewed %d times", c.LearningStage, c.NextReviewDate, c.TotalTimesReviewed)
+	}
+	if cd.Cards[2].LearningStage != Unavailable {
+		t.Errorf("Expected card 2 to be locked again, got stage %d", cd.Cards[2].LearningStage)
+	}
+	if len(cd.UpNext) != 2 || cd.UpNext[1] != c {
+		t.Errorf("Expected the card to be back in its place in the up next queue, got %v", cd.UpNext)
+	}
+
+	// The undo is saved, and the answer is left out of the review log
+	if cards := loadSavedCards(t, cd); cards[1].TotalTimesReviewed != 0 {
+		t.Errorf("Expected the undo to be saved")
+	}
+	entries, err := cd.GetCardReviewLog(1)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected the undone answer to be left out of the review log, got %v %v", entries, err)
+	}
+	raw, _ := ioutil.ReadFile(cd.ReviewLogFile())
+	if lines := strings.Count(string(raw), "\n"); lines != 2 {
+		t.Errorf("Expected the answer and the undo to be in the review log file, got %d lines", lines)
+	}
+}
+
+func TestUndoStacks(t *testing.T) {
+	cd := createUndoCardData(t)
+	cd.AnswerCardInSession("a", 1, MeaningFacet, Good, 0)
+	cd.AnswerCardInSession("b", 3, MeaningFacet, Good, 0)
+
+	// Each session only undoes its own answers
+	result, err := cd.UndoAnswer("a")
+	if err != nil || result.Card.ID != 1 {
+		t.Errorf("Expected session a to undo card 1, got %v", err)
+	}
+	if _, err = cd.UndoAnswer("a"); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected nothing left to undo, got %v", err)
+	}
+	if cd.Cards[3].TotalTimesReviewed != 1 {
+		t.Errorf("Expected session b's answer to be kept")
+	}
+
+	// A card that has changed since it was answered is left alone
+	cd.SuspendCard(3)
+	if _, err = cd.UndoAnswer("b"); !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	if cd.CanUndo("b") {
+		t.Errorf("Expected the conflicting answer to be dropped from the stack")
+	}
+
+	// Only the last few answers are kept
+	cd = createStoreCardData(t, 1)
+	for i := 0; i < undoStackSize+2; i++ {
+		cd.Cards[1].Interval = 48 // Never burned, as burned cards can't be answered
+		cd.Cards[1].NextReviewDate = "1970-01-01T00:00:00Z"
+		cd.AnswerCardInSession("a", 1, MeaningFacet, Good, 0)
+	}
+	if n := len(cd.undo["a"].entries); n != undoStackSize {
+		t.Errorf("Expected %d answers to undo, got %d", undoStackSize, n)
+	}
+}
+
+func TestUndoConflictOnUnlockedCard(t *testing.T) {
+	cd := createUndoCardData(t)
+	cd.AnswerCardInSession("a", 1, MeaningFacet, Easy, 0)
+
+	// Card 2 was unlocked by the answer, and has been queued since
+	err := cd.QueueCard(2)
+	if err != nil {
+		t.Fatalf("Error queueing card: %s", err)
+	}
+	if _, err = cd.UndoAnswer("a"); !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+	if !cd.Cards[2].QueuedToLearn || cd.Cards[1].LearningStage != Learned {
+		t.Errorf("Expected the cards to be left alone")
+	}
+}
+
+func TestApiUndo(t *testing.T) {
+	cd, h := createTestRouter(t, 1)
+	cd.Cards[1].NextReviewDate = "1970-01-01T00:00:00Z"
+
+	w := apiRequest(h, "POST", "/api/v1/srs/undo", "")
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 with nothing to undo, got %d", w.Code)
+	}
+
+	apiRequest(h, "POST", "/api/v1/srs/answers", `{"card_id": 1, "grade": "again"}`)
+	if cd.Cards[1].LearningStage != Learning {
+		t.Fatalf("Expected the card to be relearned, got stage %d", cd.Cards[1].LearningStage)
+	}
+	w = apiRequest(h, "POST", "/api/v1/srs/undo", "")
+	var result ApiUndoResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || result.Grade != "again" || result.Card.Interval != 48 || result.Card.LearningStage != Learned {
+		t.Errorf("Expected the answer to be undone, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+// A request from a browser, with its CSRF cookie and token
+func browserRequest(h http.Handler, method string, path string, csrf string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrf})
+	req.Header.Set(csrfHeader, csrf)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestSrsPageUndoLink(t *testing.T) {
+	cd, h := createTestRouter(t, 2)
+	cd.Cards[1].NextReviewDate = "1970-01-01T00:00:00Z"
+
+	w := browserRequest(h, "GET", "/srs", "one")
+	if strings.Contains(w.Body.String(), "Undo last answer") {
+		t.Errorf("Expected no undo link before answering")
+	}
+
+	browserRequest(h, "POST", "/srs/answer/1/good", "one")
+	w = browserRequest(h, "GET", "/srs", "one")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Undo last answer") {
+		t.Errorf("Expected an undo link after answering, got %d", w.Code)
+	}
+
+	// Without users every browser has the same profile, but still only undoes its own answers
+	w = browserRequest(h, "GET", "/srs", "two")
+	if strings.Contains(w.Body.String(), "Undo last answer") {
+		t.Errorf("Expected no undo link for another browser")
+	}
+	if w = browserRequest(h, "POST", "/srs/undo", "two"); w.Code != http.StatusConflict {
+		t.Errorf("Expected nothing to undo for another browser, got %d", w.Code)
+	}
+}
+
+func TestUndoStackExpires(t *testing.T) {
+	cd := createStoreCardData(t, 2)
+	cd.AnswerCardInSession("a", 1, MeaningFacet, Good, 0)
+	cd.undo["a"].lastUsed = time.Now().Add(-undoStackLifetime - time.Minute)
+	if cd.CanUndo("a") {
+		t.Errorf("Expected an old undo stack not to be used")
+	}
+
+	// Old stacks are dropped when another client answers
+	cd.AnswerCardInSession("b", 2, MeaningFacet, Good, 0)
+	if _, ok := cd.undo["a"]; ok || len(cd.undo) != 1 {
+		t.Errorf("Expected the old undo stack to be dropped, got %d stacks", len(cd.undo))
+	}
+}
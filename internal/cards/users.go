@@ -0,0 +1,235 @@
+package cards
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// Users are listed in DataDir/users.json. Each user has their own profile directory,
+// DataDir/users/<name>, holding their progress, review log, historical data and text analyses.
+// The cards file and dictionary are shared by everyone.
+// With no users file the server runs in single user mode, with no login, keeping everything in DataDir.
+
+var ErrUserExists = errors.New("user already exists")
+var ErrInvalidUserName = errors.New("user names may only contain lower case letters, numbers, - and _")
+var ErrBadLogin = errors.New("unknown user or wrong password")
+
+var validUserName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+const passwordIterations = 100000
+
+type User struct {
+	Name         string `json:"name"`
+	Salt         string `json:"salt"`          // Base64
+	PasswordHash string `json:"password_hash"` // Base64 PBKDF2-HMAC-SHA256 of the password
+}
+
+type UserStore struct {
+	Path string
+
+	mu    sync.RWMutex
+	users []User
+}
+
+func UsersFile(dataDir string) string {
+	return filepath.Join(dataDir, "users.json")
+}
+
+func ProfileDirFor(dataDir string, name string) string {
+	return filepath.Join(dataDir, "users", name)
+}
+
+// Load the users file. A missing file is not an error, and gives no users.
+func LoadUsers(path string) (*UserStore, error) {
+	us := &UserStore{Path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return us, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &us.users)
+	if err != nil {
+		return nil, err
+	}
+	return us, nil
+}
+
+func (us *UserStore) Save() error {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	data, err := json.MarshalIndent(us.users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(us.Path, data)
+}
+
+// Names of all users, sorted
+func (us *UserStore) Names() []string {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	var names []string
+	for _, u := range us.users {
+		names = append(names, u.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (us *UserStore) Add(name string, password string) error {
+	if !validUserName.MatchString(name) {
+		return fmt.Errorf("%q: %w", name, ErrInvalidUserName)
+	}
+	if password == "" {
+		return errors.New("password must not be empty")
+	}
+
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	for _, u := range us.users {
+		if u.Name == name {
+			return fmt.Errorf("%s: %w", name, ErrUserExists)
+		}
+	}
+	us.users = append(us.users, User{
+		Name:         name,
+		Salt:         base64.StdEncoding.EncodeToString(salt),
+		PasswordHash: base64.StdEncoding.EncodeToString(hashPassword(password, salt)),
+	})
+	return nil
+}
+
+// Check a user's password. Returns ErrBadLogin for an unknown user or wrong password.
+func (us *UserStore) Authenticate(name string, password string) error {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	for _, u := range us.users {
+		if u.Name != name {
+			continue
+		}
+		salt, err := base64.StdEncoding.DecodeString(u.Salt)
+		if err != nil {
+			return err
+		}
+		want, err := base64.StdEncoding.DecodeString(u.PasswordHash)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(hashPassword(password, salt), want) != 1 {
+			return ErrBadLogin
+		}
+		return nil
+	}
+	return ErrBadLogin
+}
+
+func hashPassword(password string, salt []byte) []byte {
+	return pbkdf2SHA256([]byte(password), salt, passwordIterations)
+}
+
+// PBKDF2 (RFC 8018) with HMAC-SHA256, giving a single 32 byte block
+func pbkdf2SHA256(password []byte, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1}) // Block index 1, big endian
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+// Copy the single user progress, review log, historical data, vacations and text analyses into a profile.
+// Used when the first user is added, so they keep the progress made before there were users.
+// Files that don't exist are skipped.
+func SeedProfile(dataDir string, cardsFile string, profileDir string) error {
+	err := os.MkdirAll(profileDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// Cards files from before progress was split out carry the progress themselves
+	_, err = os.Stat(filepath.Join(dataDir, "progress.json"))
+	if os.IsNotExist(err) {
+		cards, err := ReadCardsFile(cardsFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if cards != nil {
+			err = (&CardData{Cards: cards, ProfileDir: profileDir}).saveProgress()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range []string{"progress.json", "revlog.jsonl", "historical-data.csv", "vacations.json"} {
+		err := copyFileIfExists(filepath.Join(dataDir, name), filepath.Join(profileDir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(dataDir, "text_analysis"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(profileDir, "text_analysis"), 0755)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		err := copyFileIfExists(filepath.Join(dataDir, "text_analysis", f.Name()), filepath.Join(profileDir, "text_analysis", f.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFileIfExists(from string, to string) error {
+	data, err := ioutil.ReadFile(from)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(to, data)
+}
@@ -0,0 +1,87 @@
+package cards
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// Test vectors from RFC 7914, section 11
+func TestPbkdf2SHA256(t *testing.T) {
+	key := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1))
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"
+	if key != expected {
+		t.Errorf("Expected %s, got %s", expected, key)
+	}
+
+	key = hex.EncodeToString(pbkdf2SHA256([]byte("Password"), []byte("NaCl"), 80000))
+	expected = "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"
+	if key != expected {
+		t.Errorf("Expected %s, got %s", expected, key)
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	us, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("Error loading missing users file: %s", err)
+	}
+	if len(us.Names()) != 0 {
+		t.Errorf("Expected no users, got %v", us.Names())
+	}
+
+	err = us.Add("alice", "correct horse")
+	if err != nil {
+		t.Fatalf("Error adding user: %s", err)
+	}
+	err = us.Add("alice", "battery staple")
+	if !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	err = us.Add("../bob", "battery staple")
+	if !errors.Is(err, ErrInvalidUserName) {
+		t.Errorf("Expected ErrInvalidUserName, got %v", err)
+	}
+	err = us.Save()
+	if err != nil {
+		t.Fatalf("Error saving users: %s", err)
+	}
+
+	us, err = LoadUsers(path)
+	if err != nil {
+		t.Fatalf("Error loading users: %s", err)
+	}
+	if err := us.Authenticate("alice", "correct horse"); err != nil {
+		t.Errorf("Expected the right password to be accepted, got %v", err)
+	}
+	if err := us.Authenticate("alice", "correct horse "); !errors.Is(err, ErrBadLogin) {
+		t.Errorf("Expected ErrBadLogin for the wrong password, got %v", err)
+	}
+	if err := us.Authenticate("bob", "correct horse"); !errors.Is(err, ErrBadLogin) {
+		t.Errorf("Expected ErrBadLogin for an unknown user, got %v", err)
+	}
+}
+
+func TestSeedProfileKeepsVacation(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	err := cd.SaveCardMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cd.StartVacation()
+	if err != nil {
+		t.Fatalf("Error starting vacation: %s", err)
+	}
+
+	profileDir := ProfileDirFor(cd.DataDir, "alice")
+	err = SeedProfile(cd.DataDir, cd.CardsFile, profileDir)
+	if err != nil {
+		t.Fatalf("Error seeding profile: %s", err)
+	}
+	vacations, err := ReadVacationFile(filepath.Join(profileDir, "vacations.json"))
+	if err != nil || len(vacations) != 1 || vacations[0].End != "" {
+		t.Errorf("Expected the open vacation to be copied to the profile, got %v %v", vacations, err)
+	}
+}
@@ -0,0 +1,180 @@
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Vacation mode pauses reviews. While it's on nothing is due, and when it ends every scheduled review
+// is moved later by the time away, so reviews don't pile up and learning cards keep their short intervals.
+// Last review dates are left alone, as you really haven't seen the cards for that long.
+// Vacations are saved in the profile directory, so they last across restarts.
+
+var ErrOnVacation = errors.New("already on vacation")
+var ErrNotOnVacation = errors.New("not on vacation")
+
+type VacationPeriod struct {
+	Start string `json:"start"`         // RFC3339
+	End   string `json:"end,omitempty"` // RFC3339. Empty while still on vacation.
+}
+
+func (v VacationPeriod) StartTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, v.Start)
+	return t
+}
+
+// When the vacation ended, or now if it hasn't yet
+func (v VacationPeriod) EndTime(now time.Time) time.Time {
+	if v.End == "" {
+		return now
+	}
+	end, _ := time.Parse(time.RFC3339, v.End)
+	return end
+}
+
+// How long the vacation lasted, or has lasted by now
+func (v VacationPeriod) Duration(now time.Time) time.Duration {
+	return v.EndTime(now).Sub(v.StartTime())
+}
+
+// Whole days of the vacation by now
+func (v VacationPeriod) Days(now time.Time) int {
+	return int(v.Duration(now).Hours() / 24)
+}
+
+// Whether any of the vacation, as it is by now, is between start and end
+func (v VacationPeriod) Overlaps(start time.Time, end time.Time, now time.Time) bool {
+	return v.StartTime().Before(end) && !v.EndTime(now).Before(start)
+}
+
+func (cd *CardData) VacationFile() string {
+	return filepath.Join(cd.GetProfileDir(), "vacations.json")
+}
+
+// A missing vacations file means there have been no vacations
+func ReadVacationFile(path string) ([]VacationPeriod, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vacations []VacationPeriod
+	err = json.Unmarshal(b, &vacations)
+	return vacations, err
+}
+
+// Caller must hold the lock
+func (cd *CardData) saveVacations() error {
+	b, err := json.MarshalIndent(cd.Vacations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cd.VacationFile(), b)
+}
+
+// The vacation you're on, or nil if you're not on one.
+// Caller must hold the lock, or cd must be a snapshot.
+func (cd *CardData) CurrentVacation() *VacationPeriod {
+	if len(cd.Vacations) == 0 || cd.Vacations[len(cd.Vacations)-1].End != "" {
+		return nil
+	}
+	return &cd.Vacations[len(cd.Vacations)-1]
+}
+
+func (cd *CardData) StartVacation() error {
+	err := cd.Update(func() error {
+		if cd.CurrentVacation() != nil {
+			return ErrOnVacation
+		}
+		cd.Vacations = append(cd.Vacations, VacationPeriod{Start: cd.Now().Format(time.RFC3339)})
+		err := cd.saveVacations()
+		if err != nil {
+			cd.Vacations = cd.Vacations[:len(cd.Vacations)-1]
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Started vacation")
+	cd.saveVacationDay()
+	return nil
+}
+
+// End the vacation, moving every scheduled review later by its length. Returns the vacation.
+func (cd *CardData) EndVacation() (VacationPeriod, error) {
+	var v VacationPeriod
+	now := cd.Now()
+	err := cd.Update(func() error {
+		current := cd.CurrentVacation()
+		if current == nil {
+			return ErrNotOnVacation
+		}
+		v = *current
+		v.End = now.Format(time.RFC3339)
+
+		// Save the end first, so a failed save can't lead to the reviews being moved twice
+		*current = v
+		err := cd.saveVacations()
+		if err != nil {
+			current.End = ""
+			return err
+		}
+		for _, c := range cd.Cards {
+			c.shiftReviews(v.Duration(now))
+		}
+		return nil
+	})
+	if err != nil {
+		return v, err
+	}
+	log.Printf("Ended vacation, moving reviews %s later", v.Duration(now))
+	cd.saveVacationDay()
+	return v, nil
+}
+
+// Record today in the historical data, so it shows the vacation
+func (cd *CardData) saveVacationDay() {
+	err := cd.Snapshot().SaveHistoricalData()
+	if err != nil {
+		log.Printf("Error saving historical data: %s", err)
+	}
+}
+
+// Move the facets that are being reviewed later by d
+func (c *Card) shiftReviews(d time.Duration) {
+	c.NextReviewDate = shiftReviewDate(c.NextReviewDate, c.LearningStage, d)
+	if c.ReadingReview != nil {
+		c.ReadingReview.NextReviewDate = shiftReviewDate(c.ReadingReview.NextReviewDate, c.ReadingReview.LearningStage, d)
+	}
+}
+
+// Up next cards aren't scheduled yet, and burned cards are never reviewed again, so only learning and learned reviews move
+func shiftReviewDate(date string, ls LearningStage, d time.Duration) string {
+	if ls != Learning && ls != Learned {
+		return date
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+	return t.Add(d).Format(time.RFC3339)
+}
+
+// Copies of the cards as they will be if the vacation ends now
+func shiftedForVacation(cards []*Card, v *VacationPeriod, now time.Time) []*Card {
+	var shifted []*Card
+	for _, c := range cards {
+		n := c.Copy()
+		n.shiftReviews(v.Duration(now))
+		shifted = append(shifted, n)
+	}
+	return shifted
+}
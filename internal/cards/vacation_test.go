@@ -0,0 +1,135 @@
+package cards
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVacation(t *testing.T) {
+	cd := createStoreCardData(t, 3)
+	cd.Cards[2] = createKanjiCard(2)
+	cd.Cards[2].splitFacets()
+	cd.Cards[2].ReadingReview.NextReviewDate = "2020-01-02T00:00:00Z"
+	cd.Cards[3].Interval = 9600 // Burned
+
+	err := cd.StartVacation()
+	if err != nil {
+		t.Fatalf("Error starting vacation: %s", err)
+	}
+	if err = cd.StartVacation(); !errors.Is(err, ErrOnVacation) {
+		t.Errorf("Expected to already be on vacation, got %v", err)
+	}
+	if srsData := cd.GetNextSrsCard(); srsData.Card != nil {
+		t.Errorf("Expected nothing to be due on vacation, got card %d", srsData.Card.ID)
+	}
+	if result, _ := cd.AnswerCard(1, MeaningFacet, Again, 0); result.Answered {
+		t.Errorf("Expected answers to be ignored on vacation")
+	}
+
+	// Two days away
+	cd.Vacations[0].Start = time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	v, err := cd.EndVacation()
+	if err != nil {
+		t.Fatalf("Error ending vacation: %s", err)
+	}
+	if v.Days(time.Now()) != 2 {
+		t.Errorf("Expected a 2 day vacation, got %d", v.Days(time.Now()))
+	}
+	if d := cd.Cards[1].NextReviewDate; d != "2020-01-03T00:00:00Z" {
+		t.Errorf("Expected the review to move 2 days later, got %s", d)
+	}
+	if d := cd.Cards[2].ReadingReview.NextReviewDate; d != "2020-01-04T00:00:00Z" {
+		t.Errorf("Expected the reading review to move 2 days later, got %s", d)
+	}
+	if d := cd.Cards[3].NextReviewDate; d != "2020-01-01T00:00:00Z" {
+		t.Errorf("Expected a burned card not to move, got %s", d)
+	}
+	if _, err = cd.EndVacation(); !errors.Is(err, ErrNotOnVacation) {
+		t.Errorf("Expected not to be on vacation, got %v", err)
+	}
+
+	vacations, err := ReadVacationFile(cd.VacationFile())
+	if err != nil || len(vacations) != 1 || vacations[0].End == "" {
+		t.Errorf("Expected the ended vacation to be saved, got %v %v", vacations, err)
+	}
+	if cards := loadSavedCards(t, cd); cards[1].NextReviewDate != "2020-01-03T00:00:00Z" {
+		t.Errorf("Expected the moved reviews to be saved")
+	}
+}
+
+func TestVacationSchedule(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	due := time.Now().Truncate(time.Hour).Add(2 * time.Hour)
+	cd.Cards[1].NextReviewDate = due.Format(time.RFC3339)
+	cd.Vacations = []VacationPeriod{{Start: time.Now().Add(-24 * time.Hour).Format(time.RFC3339)}}
+
+	// The schedule is shown as if the vacation ends now
+	schedule, err := cd.Snapshot().GetScheduleData()
+	if err != nil {
+		t.Fatalf("Error getting the schedule: %s", err)
+	}
+	if schedule[2].Count != 0 || schedule[26].Count != 1 {
+		t.Errorf("Expected the review to show a day later, got %d in 2 hours and %d in 26", schedule[2].Count, schedule[26].Count)
+	}
+	if cd.Cards[1].NextReviewDate != due.Format(time.RFC3339) {
+		t.Errorf("Expected the schedule not to move the review")
+	}
+}
+
+func TestVacationHistoricalData(t *testing.T) {
+	cd := createStoreCardData(t, 1)
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	err := ioutil.WriteFile(cd.HistoricalDataFile(), []byte(yesterday+",1,2,3,4\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cd.StartVacation()
+	data, err := cd.GetHistoricalData()
+	if err != nil {
+		t.Fatalf("Error reading historical data: %s", err)
+	}
+	if entries := data.HistoricalDataEntries; len(entries) != 2 || entries[0].OnVacation || !entries[1].OnVacation {
+		t.Errorf("Expected only today to be marked as a vacation, got %v", entries)
+	}
+}
+
+func TestApiVacation(t *testing.T) {
+	_, h := createTestRouter(t, 1)
+
+	w := apiRequest(h, "POST", "/api/v1/vacation", "")
+	var vacation ApiVacation
+	json.Unmarshal(w.Body.Bytes(), &vacation)
+	if w.Code != http.StatusOK || !vacation.OnVacation || len(vacation.Vacations) != 1 {
+		t.Errorf("Expected to be on vacation, got %d %s", w.Code, w.Body.String())
+	}
+	if w = apiRequest(h, "POST", "/api/v1/vacation", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 when already on vacation, got %d", w.Code)
+	}
+
+	w = apiRequest(h, "GET", "/api/v1/srs/next", "")
+	var next ApiSrsNext
+	json.Unmarshal(w.Body.Bytes(), &next)
+	if next.Card != nil || !next.OnVacation {
+		t.Errorf("Expected nothing to be due on vacation, got %s", w.Body.String())
+	}
+
+	w = apiRequest(h, "DELETE", "/api/v1/vacation", "")
+	vacation = ApiVacation{}
+	json.Unmarshal(w.Body.Bytes(), &vacation)
+	if w.Code != http.StatusOK || vacation.OnVacation || vacation.Vacations[0].End == "" {
+		t.Errorf("Expected the vacation to be over, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Today has been recorded, before anything is known
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/historicalstats", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the historical stats page to show the vacation, got %d", w.Code)
+	}
+}
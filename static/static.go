@@ -0,0 +1,7 @@
+// Package static embeds the templates, stylesheets, scripts and images, so the binary can be run from anywhere.
+package static
+
+import "embed"
+
+//go:embed css html img js openapi.json
+var Files embed.FS